@@ -0,0 +1,59 @@
+// Command schema2go generates Go structs from JSON Schema files, the inverse of
+// schematic.GenerateSchema:
+//
+//	schema2go -package events -out events/types.go schemas/*.json
+//
+// Every schema becomes a struct named after its title and every definition a type.
+// Definitions shared by several files are declared once.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/sadrishehu/schematic/schematic"
+)
+
+func main() {
+	pkg := flag.String("package", "events", "name of the package of the generated source")
+	out := flag.String("out", "", "file to write the generated source to, standard output by default")
+	help := flag.Bool("help", false, "print help/usage information")
+
+	flag.Parse()
+
+	if *help || flag.NArg() == 0 {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: schema2go [flags] schema.json...")
+		flag.PrintDefaults()
+		return
+	}
+
+	schemas := make([]schematic.Schema, 0, flag.NArg())
+	for _, filename := range flag.Args() {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatalf("there was an error during file reading. Error: %s", err)
+		}
+
+		var schema schematic.Schema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			log.Fatalf("there was an error parsing %s. Error: %s", filename, err)
+		}
+		schemas = append(schemas, schema)
+	}
+
+	source, err := schematic.GenerateGo(*pkg, schemas...)
+	if err != nil {
+		log.Fatalf("there was an error during code generation. Error: %s", err)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(source)
+		return
+	}
+	if err := os.WriteFile(*out, source, 0o644); err != nil {
+		log.Fatalf("there was an error during file writing. Error: %s", err)
+	}
+}
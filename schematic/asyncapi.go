@@ -0,0 +1,176 @@
+package schematic
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// AsyncAPIVersion is the version of the AsyncAPI specification a document is written for
+type AsyncAPIVersion string
+
+const (
+	// AsyncAPI26 describes each event by a channel with a subscribe operation
+	AsyncAPI26 AsyncAPIVersion = "2.6.0"
+	// AsyncAPI30 describes each event by a channel and a separate send operation
+	AsyncAPI30 AsyncAPIVersion = "3.0.0"
+)
+
+// asyncAPIDialect writes the payload schemas in the default schema format of
+// AsyncAPI, a superset of draft-07, referring to the schemas in the components
+var asyncAPIDialect = &dialect{refPrefix: componentsRefPrefix, tupleItems: true}
+
+// AsyncAPIDocument is an AsyncAPI document describing the events produced by a service
+type AsyncAPIDocument struct {
+	AsyncAPI           AsyncAPIVersion              `json:"asyncapi"`
+	Info               AsyncAPIInfo                 `json:"info"`
+	DefaultContentType string                       `json:"defaultContentType,omitempty"`
+	Channels           map[string]AsyncAPIChannel   `json:"channels"`
+	Operations         map[string]AsyncAPIOperation `json:"operations,omitempty"`
+	Components         AsyncAPIComponents           `json:"components"`
+}
+
+// AsyncAPIInfo is the metadata of an AsyncAPI document
+type AsyncAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// AsyncAPIChannel is a channel an event is published on. Address and Messages are
+// used by AsyncAPI 3.0, Subscribe by AsyncAPI 2.6.
+type AsyncAPIChannel struct {
+	Address   string                 `json:"address,omitempty"`
+	Messages  map[string]AsyncAPIRef `json:"messages,omitempty"`
+	Subscribe *AsyncAPIOperation     `json:"subscribe,omitempty"`
+}
+
+// AsyncAPIOperation is the publication of an event. Action, Channel and Messages
+// are used by AsyncAPI 3.0, OperationID and Message by AsyncAPI 2.6.
+type AsyncAPIOperation struct {
+	Action      string        `json:"action,omitempty"`
+	Channel     *AsyncAPIRef  `json:"channel,omitempty"`
+	Messages    []AsyncAPIRef `json:"messages,omitempty"`
+	OperationID string        `json:"operationId,omitempty"`
+	Message     *AsyncAPIRef  `json:"message,omitempty"`
+}
+
+// AsyncAPIRef is a reference to another object of the document
+type AsyncAPIRef struct {
+	Ref string `json:"$ref"`
+}
+
+// AsyncAPIMessage is an event, whose payload refers to its schema in the components
+type AsyncAPIMessage struct {
+	Name    string             `json:"name"`
+	Title   string             `json:"title,omitempty"`
+	Payload PropertyDefinition `json:"payload"`
+}
+
+// AsyncAPIComponents holds the messages and the schemas shared by the channels
+type AsyncAPIComponents struct {
+	Messages map[string]AsyncAPIMessage    `json:"messages"`
+	Schemas  map[string]PropertyDefinition `json:"schemas"`
+}
+
+// GenerateAsyncAPI creates an AsyncAPI document from the schemas of the events
+// produced by a service, keyed by event name like the map given to BuildEvents.
+// Every event gets a channel named after it and a message whose payload is its
+// schema; the definitions of all events are merged into the components, so types
+// shared by several events are described once. Definitions with the same name but
+// a different content in two events are reported as an error.
+func GenerateAsyncAPI(version AsyncAPIVersion, info AsyncAPIInfo, events map[string]Schema) (AsyncAPIDocument, error) {
+	doc := AsyncAPIDocument{
+		AsyncAPI:           version,
+		Info:               info,
+		DefaultContentType: "application/json",
+		Channels:           map[string]AsyncAPIChannel{},
+		Components: AsyncAPIComponents{
+			Messages: map[string]AsyncAPIMessage{},
+			Schemas:  map[string]PropertyDefinition{},
+		},
+	}
+	if version == AsyncAPI30 {
+		doc.Operations = map[string]AsyncAPIOperation{}
+	}
+
+	names := make([]string, 0, len(events))
+	for name := range events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	owners := map[string]string{}
+	for _, name := range names {
+		schema := events[name]
+
+		for defName, def := range schema.Definitions {
+			if existing, ok := doc.Components.Schemas[defName]; ok {
+				if !sameDefinition(existing, def) {
+					return AsyncAPIDocument{}, fmt.Errorf("definition %s differs between events %s and %s", defName, owners[defName], name)
+				}
+				continue
+			}
+			doc.Components.Schemas[defName] = def
+			owners[defName] = name
+		}
+	}
+
+	for _, name := range names {
+		schema := events[name]
+		if owner, ok := owners[name]; ok {
+			return AsyncAPIDocument{}, fmt.Errorf("event %s has the name of a definition of event %s", name, owner)
+		}
+
+		doc.Components.Schemas[name] = rootProperty(schema)
+		doc.Components.Messages[name] = AsyncAPIMessage{
+			Name:    name,
+			Title:   schema.Title,
+			Payload: PropertyDefinition{Ref: defsRefPrefix + escapePointer(name)},
+		}
+		doc.addChannel(name)
+	}
+
+	return doc, nil
+}
+
+// addChannel adds the channel publishing an event and the operation sending it
+func (doc *AsyncAPIDocument) addChannel(name string) {
+	message := AsyncAPIRef{Ref: "#/components/messages/" + escapePointer(name)}
+
+	if doc.AsyncAPI != AsyncAPI30 {
+		doc.Channels[name] = AsyncAPIChannel{
+			Subscribe: &AsyncAPIOperation{OperationID: name, Message: &message},
+		}
+		return
+	}
+
+	channel := "#/channels/" + escapePointer(name)
+	doc.Channels[name] = AsyncAPIChannel{
+		Address:  name,
+		Messages: map[string]AsyncAPIRef{name: message},
+	}
+	doc.Operations[name] = AsyncAPIOperation{
+		Action:   "send",
+		Channel:  &AsyncAPIRef{Ref: channel},
+		Messages: []AsyncAPIRef{{Ref: channel + "/messages/" + escapePointer(name)}},
+	}
+}
+
+// MarshalJSON writes the schemas in the AsyncAPI schema format, referring to each
+// other with "#/components/schemas/..." references
+func (doc AsyncAPIDocument) MarshalJSON() ([]byte, error) {
+	type document AsyncAPIDocument
+
+	out := document(doc)
+	out.Components.Schemas = asyncAPIDialect.properties(doc.Components.Schemas)
+
+	messages := make(map[string]AsyncAPIMessage, len(doc.Components.Messages))
+	for name, message := range doc.Components.Messages {
+		message.Payload = asyncAPIDialect.property(message.Payload)
+		messages[name] = message
+	}
+	out.Components.Messages = messages
+
+	return json.Marshal(out)
+}
@@ -0,0 +1,111 @@
+package schematic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type AsyncTags struct {
+	EventName    string `json:"event_name"`
+	EventVersion string `json:"event_version"`
+	EventID      string `json:"event_id"`
+}
+
+type AsyncOrderPlaced struct {
+	Tags    AsyncTags `json:"tags"`
+	OrderID string    `json:"order_id"`
+}
+
+type AsyncOrderShipped struct {
+	Tags AsyncTags `json:"tags"`
+}
+
+func asyncEvents() map[string]Schema {
+	return map[string]Schema{
+		"order.placed":  GenerateSchema(AsyncOrderPlaced{}, "Order Placed", string(Draft07)),
+		"order.shipped": GenerateSchema(AsyncOrderShipped{}, "Order Shipped", string(Draft07)),
+	}
+}
+
+func TestAsyncAPI26(t *testing.T) {
+	doc, err := GenerateAsyncAPI(AsyncAPI26, AsyncAPIInfo{Title: "Orders", Version: "1.0.0"}, asyncEvents())
+	require.NoError(t, err)
+
+	marshaled, err := json.Marshal(doc)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"asyncapi": "2.6.0",
+		"info": {"title": "Orders", "version": "1.0.0"},
+		"defaultContentType": "application/json",
+		"channels": {
+			"order.placed": {"subscribe": {"operationId": "order.placed", "message": {"$ref": "#/components/messages/order.placed"}}},
+			"order.shipped": {"subscribe": {"operationId": "order.shipped", "message": {"$ref": "#/components/messages/order.shipped"}}}
+		},
+		"components": {
+			"messages": {
+				"order.placed": {"name": "order.placed", "title": "Order Placed", "payload": {"$ref": "#/components/schemas/order.placed"}},
+				"order.shipped": {"name": "order.shipped", "title": "Order Shipped", "payload": {"$ref": "#/components/schemas/order.shipped"}}
+			},
+			"schemas": {
+				"AsyncTags": {"type": "object", "description": "Tags", "required": ["event_name", "event_version", "event_id"], "properties": {
+					"event_name": {"type": "string", "description": "EventName"},
+					"event_version": {"type": "string", "description": "EventVersion"},
+					"event_id": {"type": "string", "description": "EventID"}
+				}},
+				"order.placed": {"type": "object", "required": ["tags", "order_id"], "properties": {
+					"tags": {"$ref": "#/components/schemas/AsyncTags", "description": "Tags"},
+					"order_id": {"type": "string", "description": "OrderID"}
+				}},
+				"order.shipped": {"type": "object", "required": ["tags"], "properties": {
+					"tags": {"$ref": "#/components/schemas/AsyncTags", "description": "Tags"}
+				}}
+			}
+		}
+	}`, string(marshaled))
+}
+
+func TestAsyncAPI30(t *testing.T) {
+	doc, err := GenerateAsyncAPI(AsyncAPI30, AsyncAPIInfo{Title: "Orders", Version: "1.0.0"}, asyncEvents())
+	require.NoError(t, err)
+
+	marshaled, err := json.Marshal(doc.Channels["order.placed"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"address": "order.placed", "messages": {"order.placed": {"$ref": "#/components/messages/order.placed"}}}`, string(marshaled))
+
+	marshaled, err = json.Marshal(doc.Operations["order.placed"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"action": "send",
+		"channel": {"$ref": "#/channels/order.placed"},
+		"messages": [{"$ref": "#/channels/order.placed/messages/order.placed"}]
+	}`, string(marshaled))
+
+	require.Len(t, doc.Components.Schemas, 3)
+}
+
+func TestAsyncAPIConflictingDefinitions(t *testing.T) {
+	events := asyncEvents()
+	shipped := events["order.shipped"]
+	shipped.Definitions = map[string]PropertyDefinition{"AsyncTags": {Type: typeOf("string")}}
+	events["order.shipped"] = shipped
+
+	_, err := GenerateAsyncAPI(AsyncAPI26, AsyncAPIInfo{Title: "Orders", Version: "1.0.0"}, events)
+	require.ErrorContains(t, err, "definition AsyncTags differs between events order.placed and order.shipped")
+}
+
+type AsyncOrderCancelled struct {
+	Meta AsyncTags `json:"meta"`
+}
+
+func TestAsyncAPISharedDefinitionUnderOtherFieldName(t *testing.T) {
+	events := asyncEvents()
+	events["order.cancelled"] = GenerateSchema(AsyncOrderCancelled{}, "Order Cancelled", string(Draft07))
+
+	// The definition is described by the field first referencing it, Tags or Meta
+	doc, err := GenerateAsyncAPI(AsyncAPI26, AsyncAPIInfo{Title: "Orders", Version: "1.0.0"}, events)
+	require.NoError(t, err)
+	require.Equal(t, "Meta", doc.Components.Schemas["AsyncTags"].Description)
+	require.Len(t, doc.Components.Schemas, 4)
+}
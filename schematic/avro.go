@@ -0,0 +1,240 @@
+package schematic
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// AvroRecord is an Avro record schema. Marshaled as JSON it is the content of an
+// .avsc file.
+type AvroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []AvroField `json:"fields"`
+}
+
+// AvroField is a field of an Avro record. Its type is the name of a primitive or
+// named type, a union ([]interface{}) or a complex type such as AvroArray.
+type AvroField struct {
+	Name    string          `json:"name"`
+	Type    interface{}     `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+// AvroArray is an Avro array schema
+type AvroArray struct {
+	Type  string      `json:"type"`
+	Items interface{} `json:"items"`
+}
+
+// AvroMap is an Avro map schema, whose keys are always strings
+type AvroMap struct {
+	Type   string      `json:"type"`
+	Values interface{} `json:"values"`
+}
+
+// AvroLogical is an Avro primitive annotated with a logical type, e.g. a long
+// holding a timestamp-millis
+type AvroLogical struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType"`
+}
+
+// avroNull is the null type of the unions describing pointers and their default
+const avroNull = "null"
+
+// avroTypes maps well known types and scalar kinds to Avro types
+var avroTypes = map[string]interface{}{
+	"time.Time":       AvroLogical{Type: "long", LogicalType: "timestamp-millis"},
+	"uuid.UUID":       AvroLogical{Type: "string", LogicalType: "uuid"},
+	"json.RawMessage": "string",
+	"string":          "string",
+	"bool":            "boolean",
+	"float32":         "float",
+	"float64":         "double",
+	"int8":            "int",
+	"int16":           "int",
+	"int32":           "int",
+	"uint8":           "int",
+	"uint16":          "int",
+	"int":             "long",
+	"int64":           "long",
+	"uint32":          "long",
+}
+
+// avroPrimitives maps the JSON Schema types of custom type mappings to Avro types
+var avroPrimitives = map[string]interface{}{
+	"string":  "string",
+	"integer": "long",
+	"number":  "double",
+	"boolean": "boolean",
+}
+
+// invalidAvroName matches the characters not allowed in Avro names
+var invalidAvroName = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// avroContext tracks state while an Avro schema is generated
+type avroContext struct {
+	schema    *schemaContext
+	namespace string
+	pkgPath   string
+	// defined holds the full names of the records already written, which are
+	// referred to by name afterwards, and names the types they were written for
+	defined map[reflect.Type]string
+	names   map[string]reflect.Type
+	// err is the first record name used by two types
+	err error
+}
+
+// GenerateAvro creates an Avro record schema from a Go struct type, with the
+// records of nested structs in the namespace
+func GenerateAvro[T any](object T, namespace string, opts ...Option) (AvroRecord, error) {
+	return NewReflector(opts...).Avro(object, namespace)
+}
+
+// Avro creates an Avro record schema from a Go struct value. Fields are named and
+// promoted like in the JSON Schema, pointers become unions with null defaulting to
+// null and structs become named records. Records of types from other packages than
+// the root struct are placed in a sub-namespace named after their package, records
+// used more than once or recursively are referred to by name. Only structs, or
+// pointers to them, have a record, and two types can't have records of the same
+// name, e.g. types of the same name from packages of the same name.
+func (r *Reflector) Avro(object interface{}, namespace string) (AvroRecord, error) {
+	t, ok := structOf(reflect.TypeOf(object))
+	if !ok {
+		return AvroRecord{}, fmt.Errorf("an Avro record must be a struct, got %T", object)
+	}
+
+	ctx := &avroContext{
+		schema:    r.newContext(),
+		namespace: namespace,
+		pkgPath:   t.PkgPath(),
+		defined:   map[reflect.Type]string{},
+		names:     map[string]reflect.Type{},
+	}
+	record := ctx.record(t, "")
+	if ctx.err != nil {
+		return AvroRecord{}, ctx.err
+	}
+	return record, nil
+}
+
+// record creates the record of a struct. Anonymous structs are named after the
+// record and field containing them.
+func (ctx *avroContext) record(t reflect.Type, name string) AvroRecord {
+	record := AvroRecord{
+		Type:      "record",
+		Name:      avroName(name),
+		Namespace: ctx.namespace,
+		Fields:    []AvroField{},
+	}
+	if t.Name() != "" {
+		record.Name = avroName(shortTypeName(t))
+	}
+	if t.PkgPath() != "" && t.PkgPath() != ctx.pkgPath {
+		record.Namespace = strings.TrimPrefix(ctx.namespace+"."+avroName(packageName(t.PkgPath())), ".")
+	}
+
+	fullName := strings.TrimPrefix(record.Namespace+"."+record.Name, ".")
+	if other, taken := ctx.names[fullName]; taken && ctx.err == nil {
+		ctx.err = fmt.Errorf("%s and %s have the same Avro record name %s", avroTypeName(other), avroTypeName(t), fullName)
+	}
+	ctx.defined[t] = fullName
+	ctx.names[fullName] = t
+
+	for _, field := range structFields(t, ctx.schema.reflector.naming()) {
+		avroField := AvroField{
+			Name: avroName(field.name),
+			Type: ctx.avroType(field.Type, record.Name+field.Name),
+		}
+		if field.Type.Kind() == reflect.Ptr {
+			avroField.Default = json.RawMessage(avroNull)
+		}
+		record.Fields = append(record.Fields, avroField)
+	}
+
+	return record
+}
+
+// avroType describes a Go type, recursing into pointers, collections and structs.
+// name is the record name given to anonymous structs.
+func (ctx *avroContext) avroType(t reflect.Type, name string) interface{} {
+	// A union can't hold another union, so every level of pointers makes a single one
+	if t.Kind() == reflect.Ptr {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		return []interface{}{avroNull, ctx.avroType(t, name)}
+	}
+
+	if avroType, ok := formatType(ctx.schema, t, "Avro", avroPrimitives, avroTypes); ok {
+		return avroType
+	}
+
+	if fullName, ok := ctx.defined[t]; ok {
+		return fullName
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		// []byte is written by encoding/json as base64, in Avro it is plain bytes
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+		return AvroArray{Type: "array", Items: ctx.avroType(t.Elem(), name)}
+	case reflect.Array:
+		return AvroArray{Type: "array", Items: ctx.avroType(t.Elem(), name)}
+	case reflect.Map:
+		return AvroMap{Type: "map", Values: ctx.avroType(t.Elem(), name)}
+	case reflect.Struct:
+		return ctx.record(t, name)
+	case reflect.Interface:
+		ctx.schema.warnOnce(t, "schematic: %s can hold any value, it is written as an Avro string", t)
+		return "string"
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		ctx.schema.warnOnce(t, "schematic: %s can exceed the largest Avro long, it is written as a long", t)
+		return "long"
+	}
+
+	if avroType, ok := avroTypes[t.Kind().String()]; ok {
+		return avroType
+	}
+	return "string"
+}
+
+// avroTypeName names a struct type in errors, with its full package path
+func avroTypeName(t reflect.Type) string {
+	if t.Name() == "" {
+		return t.String()
+	}
+	return fullPathTypeName(t)
+}
+
+// avroName turns a name into a valid Avro name, replacing the characters that
+// aren't allowed with underscores
+func avroName(name string) string {
+	name = invalidAvroName.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// BuildAvro writes the Avro schemas of events to .avsc files named like the JSON
+// Schema files written by BuildEvents
+func BuildAvro(path *string, genAvro map[string]AvroRecord) error {
+	files := make(map[string][]byte, len(genAvro))
+	for name, record := range genAvro {
+		marshal, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error while marshaling Avro schema %s: %w", record.Name, err)
+		}
+		files[eventFileName(name, ".avsc")] = marshal
+	}
+
+	return writeFiles(path, files)
+}
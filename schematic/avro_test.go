@@ -0,0 +1,144 @@
+package schematic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sadrishehu/schematic/schematic/internal/billing"
+	billingv2 "github.com/sadrishehu/schematic/schematic/internal/billing/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type AvroNode struct {
+	Name     string      `json:"name"`
+	Children []*AvroNode `json:"children"`
+}
+
+type AvroEvent struct {
+	ID        string             `json:"id"`
+	Count     int32              `json:"count"`
+	Total     int64              `json:"total"`
+	Price     float64            `json:"price"`
+	Paid      bool               `json:"paid"`
+	Note      *string            `json:"note"`
+	CreatedAt time.Time          `json:"created_at"`
+	Payload   []byte             `json:"payload"`
+	Labels    map[string]string  `json:"labels"`
+	Billing   billing.Address    `json:"billing"`
+	Tree      AvroNode           `json:"tree"`
+	Extra     struct{ Key int8 } `json:"extra"`
+}
+
+func TestAvro(t *testing.T) {
+	record, err := GenerateAvro(AvroEvent{}, "com.acme.events")
+	require.NoError(t, err)
+
+	marshaled, err := json.Marshal(record)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"type": "record", "name": "AvroEvent", "namespace": "com.acme.events",
+		"fields": [
+			{"name": "id", "type": "string"},
+			{"name": "count", "type": "int"},
+			{"name": "total", "type": "long"},
+			{"name": "price", "type": "double"},
+			{"name": "paid", "type": "boolean"},
+			{"name": "note", "type": ["null", "string"], "default": null},
+			{"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+			{"name": "payload", "type": "bytes"},
+			{"name": "labels", "type": {"type": "map", "values": "string"}},
+			{"name": "billing", "type": {"type": "record", "name": "Address", "namespace": "com.acme.events.billing", "fields": [
+				{"name": "name", "type": "string"},
+				{"name": "line1", "type": "string"},
+				{"name": "country", "type": "string"}
+			]}},
+			{"name": "tree", "type": {"type": "record", "name": "AvroNode", "namespace": "com.acme.events", "fields": [
+				{"name": "name", "type": "string"},
+				{"name": "children", "type": {"type": "array", "items": ["null", "com.acme.events.AvroNode"]}}
+			]}},
+			{"name": "extra", "type": {"type": "record", "name": "AvroEventExtra", "namespace": "com.acme.events", "fields": [
+				{"name": "Key", "type": "int"}
+			]}}
+		]
+	}`, string(marshaled))
+}
+
+func TestAvroNotAStruct(t *testing.T) {
+	_, err := GenerateAvro(42, "com.acme.events")
+	require.EqualError(t, err, "an Avro record must be a struct, got int")
+}
+
+func TestAvroTypeMapping(t *testing.T) {
+	type Amount struct{ Units, Nanos int }
+	type Invoice struct {
+		Amount Amount `json:"amount"`
+	}
+
+	record, err := NewReflector(WithTypeMapping(reflect.TypeOf(Amount{}), PropertyDefinition{Type: typeOf("string")})).Avro(Invoice{}, "")
+	require.NoError(t, err)
+	require.Equal(t, "string", record.Fields[0].Type)
+	require.Equal(t, "", record.Namespace)
+}
+
+func TestAvroPointerToPointer(t *testing.T) {
+	type Counter struct {
+		Value **int       `json:"value"`
+		Items []***string `json:"items"`
+	}
+
+	// Unions can't be nested, every level of pointers gives a single union
+	record, err := GenerateAvro(Counter{}, "")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"null", "long"}, record.Fields[0].Type)
+	require.Equal(t, json.RawMessage("null"), record.Fields[0].Default)
+	require.Equal(t, AvroArray{Type: "array", Items: []interface{}{"null", "string"}}, record.Fields[1].Type)
+}
+
+func TestAvroUnsigned(t *testing.T) {
+	type Counter struct {
+		Small uint32 `json:"small"`
+		Large uint64 `json:"large"`
+		Size  uint   `json:"size"`
+	}
+
+	var warnings []string
+	warnf := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+	record, err := GenerateAvro(Counter{}, "", WithWarnings(warnf))
+	require.NoError(t, err)
+
+	// Avro has no unsigned types, the largest uint64 values don't fit in a long
+	require.Equal(t, []interface{}{"long", "long", "long"}, []interface{}{record.Fields[0].Type, record.Fields[1].Type, record.Fields[2].Type})
+	require.Equal(t, []string{
+		"schematic: uint64 can exceed the largest Avro long, it is written as a long",
+		"schematic: uint can exceed the largest Avro long, it is written as a long",
+	}, warnings)
+}
+
+func TestAvroNameCollision(t *testing.T) {
+	type Customer struct {
+		Billing  billing.Address   `json:"billing"`
+		Shipping billingv2.Address `json:"shipping"`
+	}
+
+	// Both addresses would be the record com.acme.events.billing.Address
+	_, err := GenerateAvro(Customer{}, "com.acme.events")
+	require.EqualError(t, err, "github.com/sadrishehu/schematic/schematic/internal/billing.Address and github.com/sadrishehu/schematic/schematic/internal/billing/v2.Address have the same Avro record name com.acme.events.billing.Address")
+}
+
+func TestBuildAvro(t *testing.T) {
+	path := t.TempDir()
+	record, err := GenerateAvro(AvroNode{}, "com.acme")
+	require.NoError(t, err)
+	require.NoError(t, BuildAvro(&path, map[string]AvroRecord{"event.name": record}))
+
+	data, err := os.ReadFile(filepath.Join(path, "event_name.avsc"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"name": "AvroNode"`)
+}
@@ -8,9 +8,55 @@ import (
 	"strings"
 )
 
+// BuildOption configures how BuildEvents writes the schemas, and BuildProto the proto files
+type BuildOption func(*buildConfig)
+
+// buildConfig holds the settings of BuildEvents and BuildProto
+type buildConfig struct {
+	common string
+}
+
+// WithCommonDefinitions writes the definitions that several event schemas share,
+// such as the event tags, once to a common schema named like an event, e.g.
+// "common" for common.json. The event schemas refer to them with $ref relative to
+// their own file, and the common schema shares the $id base URI of the schemas
+// referring to it. BuildProto writes the messages several files declare to
+// common.proto in the same way.
+func WithCommonDefinitions(name string) BuildOption {
+	return func(c *buildConfig) {
+		c.common = name
+	}
+}
+
 // BuildEvents generates JSON Schema files from the provided schema definitions
 // It creates the directory structure if it doesn't exist and writes each schema to a separate file
-func BuildEvents(path *string, genSchema map[string]Schema) error {
+func BuildEvents(path *string, genSchema map[string]Schema, opts ...BuildOption) error {
+	config := &buildConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	files, err := config.files(genSchema)
+	if err != nil {
+		return err
+	}
+
+	contents := make(map[string][]byte, len(files))
+	for filename, schema := range files {
+		marshal, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error while marshaling schema %s: %w", schema.Title, err)
+		}
+		contents[filename] = marshal
+	}
+
+	return writeFiles(path, contents)
+}
+
+// writeFiles writes the contents of files keyed by their file name to the directory
+// at path, adding a trailing slash to the path and creating the directory if it
+// doesn't exist. It is shared by BuildEvents and the exporters of other formats.
+func writeFiles(path *string, files map[string][]byte) error {
 	endsWithSlash := regexp.MustCompile("/$")
 
 	if !endsWithSlash.MatchString(*path) {
@@ -24,14 +70,9 @@ func BuildEvents(path *string, genSchema map[string]Schema) error {
 		}
 	}
 
-	for name, schema := range genSchema {
-		marshal, err := json.MarshalIndent(schema, "", "  ")
-		if err != nil {
-			return fmt.Errorf("error while marshaling schema %s: %w", schema.Title, err)
-		}
-		filename := buildFileName(name)
+	for filename, content := range files {
 		filename = *path + filename
-		err = os.WriteFile(filename, marshal, 0o644)
+		err := os.WriteFile(filename, content, 0o644)
 		if err != nil {
 			return fmt.Errorf("error while writing file %s: %w", filename, err)
 		}
@@ -40,7 +81,63 @@ func BuildEvents(path *string, genSchema map[string]Schema) error {
 	return nil
 }
 
+// files returns the schemas to write keyed by their file name, with their $id set
+// and the shared definitions moved to the common schema
+func (c *buildConfig) files(genSchema map[string]Schema) (map[string]Schema, error) {
+	files := make(map[string]Schema, len(genSchema)+1)
+	for name, schema := range genSchema {
+		files[buildFileName(name)] = schema
+	}
+
+	if c.common != "" {
+		commonFile := buildFileName(c.common)
+		if _, exists := files[commonFile]; exists {
+			return nil, fmt.Errorf("common schema %s has the name of an event", commonFile)
+		}
+
+		common := extractCommonDefinitions(genSchema, commonFile)
+		if len(common.definitions) > 0 {
+			// Only the schemas referring to the common schema need to be of its draft,
+			// and to share the base URI their relative references resolve against
+			var schemaURL, idBase string
+			referring := 0
+			for filename, schema := range files {
+				if !common.usedBy(schema) {
+					continue
+				}
+				if referring > 0 && schema.Schema != schemaURL {
+					return nil, fmt.Errorf("common schema %s can't be shared by schemas of different drafts", commonFile)
+				}
+				if referring > 0 && schema.idBase != idBase {
+					return nil, fmt.Errorf("common schema %s can't be shared by schemas of different $id base URIs", commonFile)
+				}
+				schemaURL, idBase = schema.Schema, schema.idBase
+				referring++
+				files[filename] = common.apply(schema)
+			}
+			commonSchema := common.schema(schemaURL, c.common)
+			commonSchema.idBase = idBase
+			files[commonFile] = commonSchema
+		}
+	}
+
+	// The $id is made of the base URI set by the Reflector and the file name
+	for filename, schema := range files {
+		if schema.idBase != "" {
+			schema.ID = schema.idBase + filename
+			files[filename] = schema
+		}
+	}
+
+	return files, nil
+}
+
 func buildFileName(name string) string {
-	filename := strings.ReplaceAll(name, ".", "_") + ".json"
-	return filename
+	return eventFileName(name, ".json")
+}
+
+// eventFileName returns the name of the file of an event with the extension of its
+// format, e.g. "order_placed.avsc" for "order.placed"
+func eventFileName(name, extension string) string {
+	return strings.ReplaceAll(name, ".", "_") + extension
 }
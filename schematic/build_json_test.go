@@ -0,0 +1,198 @@
+package schematic
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type SharedTags struct {
+	EventName    string `json:"event_name"`
+	EventVersion string `json:"event_version"`
+	EventID      string `json:"event_id"`
+}
+
+type OrderPlaced struct {
+	Tags    SharedTags `json:"tags"`
+	OrderID string     `json:"order_id"`
+}
+
+type OrderShipped struct {
+	Tags    SharedTags `json:"tags"`
+	Carrier string     `json:"carrier"`
+}
+
+func readSchemaFile(t *testing.T, filename string) map[string]interface{} {
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	return schema
+}
+
+func TestBuildEvents(t *testing.T) {
+	path := t.TempDir()
+	genSchema := map[string]Schema{
+		"order.placed": GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft202012)),
+	}
+
+	require.NoError(t, BuildEvents(&path, genSchema))
+
+	schema := readSchemaFile(t, filepath.Join(path, "order_placed.json"))
+	require.NotContains(t, schema, "$id")
+	require.Contains(t, schema["$defs"], "SharedTags")
+}
+
+func TestBuildEventsEmptyStruct(t *testing.T) {
+	path := t.TempDir()
+	require.NoError(t, BuildEvents(&path, map[string]Schema{
+		"heartbeat": GenerateSchema(struct{}{}, "Heartbeat", string(Draft07)),
+	}))
+
+	// Event schemas always write their type and properties
+	schema := readSchemaFile(t, filepath.Join(path, "heartbeat.json"))
+	require.Equal(t, "object", schema["type"])
+	require.Equal(t, map[string]interface{}{}, schema["properties"])
+}
+
+func TestBuildEventsCommonDefinitions(t *testing.T) {
+	path := t.TempDir()
+	genSchema := map[string]Schema{
+		"order.placed":  GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft07)),
+		"order.shipped": GenerateSchema(OrderShipped{}, "Order Shipped", string(Draft07)),
+	}
+
+	require.NoError(t, BuildEvents(&path, genSchema, WithCommonDefinitions("common")))
+
+	common := readSchemaFile(t, filepath.Join(path, "common.json"))
+	require.Equal(t, string(Draft07), common["$schema"])
+	require.Contains(t, common["definitions"], "SharedTags")
+	require.NotContains(t, common, "type")
+	require.NotContains(t, common, "properties")
+
+	for _, filename := range []string{"order_placed.json", "order_shipped.json"} {
+		schema := readSchemaFile(t, filepath.Join(path, filename))
+		require.NotContains(t, schema, "definitions")
+		tags := schema["properties"].(map[string]interface{})["tags"].(map[string]interface{})
+		require.Equal(t, "common.json#/definitions/SharedTags", tags["$ref"])
+	}
+
+	// The schemas given to BuildEvents are left untouched
+	require.Equal(t, "#/$defs/SharedTags", genSchema["order.placed"].Properties["tags"].Ref)
+}
+
+type OrderReturned struct {
+	Meta     SharedTags `json:"meta"`
+	ReturnID string     `json:"return_id"`
+}
+
+func TestBuildEventsCommonDefinitionsUnderOtherFieldNames(t *testing.T) {
+	path := t.TempDir()
+	genSchema := map[string]Schema{
+		"order.placed":   GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft202012)),
+		"order.returned": GenerateSchema(OrderReturned{}, "Order Returned", string(Draft202012)),
+	}
+
+	require.NoError(t, BuildEvents(&path, genSchema, WithCommonDefinitions("common")))
+
+	// SharedTags is the same type although it is described as Tags in one event and Meta in the other
+	common := readSchemaFile(t, filepath.Join(path, "common.json"))
+	require.Contains(t, common["$defs"], "SharedTags")
+
+	returned := readSchemaFile(t, filepath.Join(path, "order_returned.json"))
+	require.NotContains(t, returned, "$defs")
+	meta := returned["properties"].(map[string]interface{})["meta"].(map[string]interface{})
+	require.Equal(t, "common.json#/$defs/SharedTags", meta["$ref"])
+}
+
+func TestBuildEventsIDBaseURI(t *testing.T) {
+	reflector := NewReflector(WithDraft(Draft202012), WithIDBaseURI("https://schemas.acme.com/events/"))
+	genSchema := map[string]Schema{
+		"order.placed":  reflector.Reflect(OrderPlaced{}, "Order Placed"),
+		"order.shipped": reflector.Reflect(OrderShipped{}, "Shipment Sent"),
+	}
+
+	path := t.TempDir()
+	require.NoError(t, BuildEvents(&path, genSchema, WithCommonDefinitions("common")))
+
+	// The $id follows the file the event is written to, not its title, and the
+	// common schema the relative references resolve to shares the base URI
+	schema := readSchemaFile(t, filepath.Join(path, "order_shipped.json"))
+	require.Equal(t, "https://schemas.acme.com/events/order_shipped.json", schema["$id"])
+	tags := schema["properties"].(map[string]interface{})["tags"].(map[string]interface{})
+	require.Equal(t, "common.json#/$defs/SharedTags", tags["$ref"])
+
+	common := readSchemaFile(t, filepath.Join(path, "common.json"))
+	require.Equal(t, "https://schemas.acme.com/events/common.json", common["$id"])
+
+	// Without a base URI the schemas have no $id
+	path = t.TempDir()
+	genSchema["order.placed"] = GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft202012))
+	require.NoError(t, BuildEvents(&path, genSchema))
+	require.NotContains(t, readSchemaFile(t, filepath.Join(path, "order_placed.json")), "$id")
+}
+
+func TestBuildEventsIDBaseURIDraft04(t *testing.T) {
+	path := t.TempDir()
+	genSchema := map[string]Schema{
+		"order.placed": GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft04), WithIDBaseURI("https://schemas.acme.com/events/")),
+	}
+
+	require.NoError(t, BuildEvents(&path, genSchema))
+
+	// Draft-04 names the identifier id, and it is read back as the $id of the schema
+	data, err := os.ReadFile(filepath.Join(path, "order_placed.json"))
+	require.NoError(t, err)
+	var written map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &written))
+	require.Equal(t, "https://schemas.acme.com/events/order_placed.json", written["id"])
+	require.NotContains(t, written, "$id")
+
+	var schema Schema
+	require.NoError(t, json.Unmarshal(data, &schema))
+	require.Equal(t, "https://schemas.acme.com/events/order_placed.json", schema.ID)
+	require.Equal(t, genSchema["order.placed"].Properties, schema.Properties)
+}
+
+func TestBuildEventsCommonDefinitionsErrors(t *testing.T) {
+	path := t.TempDir()
+
+	err := BuildEvents(&path, map[string]Schema{
+		"common":        GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft07)),
+		"order.shipped": GenerateSchema(OrderShipped{}, "Order Shipped", string(Draft07)),
+	}, WithCommonDefinitions("common"))
+	require.ErrorContains(t, err, "has the name of an event")
+
+	err = BuildEvents(&path, map[string]Schema{
+		"order.placed":  GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft07)),
+		"order.shipped": GenerateSchema(OrderShipped{}, "Order Shipped", string(Draft202012)),
+	}, WithCommonDefinitions("common"))
+	require.ErrorContains(t, err, "different drafts")
+
+	err = BuildEvents(&path, map[string]Schema{
+		"order.placed":  GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft07), WithIDBaseURI("https://schemas.acme.com/orders/")),
+		"order.shipped": GenerateSchema(OrderShipped{}, "Order Shipped", string(Draft07), WithIDBaseURI("https://schemas.acme.com/shipping/")),
+	}, WithCommonDefinitions("common"))
+	require.ErrorContains(t, err, "different $id base URIs")
+}
+
+func TestBuildEventsCommonDefinitionsOtherDraft(t *testing.T) {
+	path := t.TempDir()
+
+	// The address schema doesn't use the shared tags, so it can be of another draft
+	err := BuildEvents(&path, map[string]Schema{
+		"order.placed":    GenerateSchema(OrderPlaced{}, "Order Placed", string(Draft07)),
+		"order.shipped":   GenerateSchema(OrderShipped{}, "Order Shipped", string(Draft07)),
+		"address.changed": GenerateSchema(Address{}, "Address Changed", string(Draft202012)),
+	}, WithCommonDefinitions("common"))
+	require.NoError(t, err)
+
+	common := readSchemaFile(t, filepath.Join(path, "common.json"))
+	require.Equal(t, string(Draft07), common["$schema"])
+	address := readSchemaFile(t, filepath.Join(path, "address_changed.json"))
+	require.Equal(t, string(Draft202012), address["$schema"])
+}
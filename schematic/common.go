@@ -0,0 +1,135 @@
+package schematic
+
+import (
+	"sort"
+	"strings"
+)
+
+// commonDefinitions holds the definitions shared by several event schemas, which
+// are written once to a common schema and referenced from the event schemas
+type commonDefinitions struct {
+	// ref is the URI of the common schema used in the references to it
+	ref         string
+	definitions map[string]PropertyDefinition
+}
+
+// extractCommonDefinitions finds the definitions that several schemas hold under the
+// same name with the same content, whatever the fields referencing them.
+// Definitions referring to a definition that isn't shared stay in the event
+// schemas, so the common schema is self-contained.
+func extractCommonDefinitions(schemas map[string]Schema, ref string) *commonDefinitions {
+	events := make([]string, 0, len(schemas))
+	for event := range schemas {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+
+	seen := map[string]int{}
+	candidates := map[string]PropertyDefinition{}
+	conflicting := map[string]bool{}
+	for _, event := range events {
+		for name, def := range schemas[event].Definitions {
+			seen[name]++
+			if first, ok := candidates[name]; !ok {
+				candidates[name] = def
+			} else if !sameDefinition(first, def) {
+				conflicting[name] = true
+			}
+		}
+	}
+
+	shared := map[string]PropertyDefinition{}
+	for name, def := range candidates {
+		if seen[name] > 1 && !conflicting[name] {
+			shared[name] = def
+		}
+	}
+
+	// Drop the definitions referring to definitions that aren't shared until none is left
+	for changed := true; changed; {
+		changed = false
+		for name, def := range shared {
+			for _, target := range localReferences(def) {
+				if _, ok := shared[target]; !ok {
+					delete(shared, name)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	return &commonDefinitions{ref: ref, definitions: shared}
+}
+
+// localReferences lists the names of the definitions a property refers to within its own schema
+func localReferences(property PropertyDefinition) []string {
+	var names []string
+	property.transform(func(p PropertyDefinition) PropertyDefinition {
+		if strings.HasPrefix(p.Ref, defsRefPrefix) {
+			names = append(names, unescapePointer(strings.TrimPrefix(p.Ref, defsRefPrefix)))
+		}
+		return p
+	})
+	return names
+}
+
+// schema returns the common schema holding the shared definitions
+func (c *commonDefinitions) schema(schemaURL, title string) Schema {
+	return Schema{
+		Schema:      schemaURL,
+		Title:       title,
+		Definitions: c.definitions,
+
+		definitionsOnly: true,
+	}
+}
+
+// usedBy reports whether an event schema holds one of the shared definitions, which
+// it then refers to in the common schema
+func (c *commonDefinitions) usedBy(schema Schema) bool {
+	for name := range schema.Definitions {
+		if _, ok := c.definitions[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns a copy of an event schema without the shared definitions, referring
+// to them in the common schema instead
+func (c *commonDefinitions) apply(schema Schema) Schema {
+	rewrite := func(p PropertyDefinition) PropertyDefinition {
+		if !strings.HasPrefix(p.Ref, defsRefPrefix) {
+			return p
+		}
+		if _, ok := c.definitions[unescapePointer(strings.TrimPrefix(p.Ref, defsRefPrefix))]; ok {
+			p.Ref = c.ref + p.Ref
+		}
+		return p
+	}
+
+	properties := make(map[string]PropertyDefinition, len(schema.Properties))
+	for name, property := range schema.Properties {
+		properties[name] = property.transform(rewrite)
+	}
+	schema.Properties = properties
+	if schema.AdditionalProperties != nil {
+		additional := schema.AdditionalProperties.transform(rewrite)
+		schema.AdditionalProperties = &additional
+	}
+
+	var definitions map[string]PropertyDefinition
+	for name, def := range schema.Definitions {
+		if _, ok := c.definitions[name]; ok {
+			continue
+		}
+		if definitions == nil {
+			definitions = map[string]PropertyDefinition{}
+		}
+		definitions[name] = def.transform(rewrite)
+	}
+	schema.Definitions = definitions
+
+	return schema
+}
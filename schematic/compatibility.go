@@ -0,0 +1,485 @@
+package schematic
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CompatibilityMode is the kind of compatibility required between versions of a
+// schema, named like the modes of schema registries
+type CompatibilityMode string
+
+const (
+	// Backward requires that consumers using the new schema can read events produced
+	// with the previous one: every payload valid before stays valid
+	Backward CompatibilityMode = "BACKWARD"
+	// Forward requires that consumers using the previous schema can read events
+	// produced with the new one: every payload valid now was valid before
+	Forward CompatibilityMode = "FORWARD"
+	// Full requires both backward and forward compatibility
+	Full CompatibilityMode = "FULL"
+
+	// The transitive modes check the new schema against every previous version
+	// instead of the latest one only, see CheckCompatibilityHistory
+	BackwardTransitive CompatibilityMode = "BACKWARD_TRANSITIVE"
+	ForwardTransitive  CompatibilityMode = "FORWARD_TRANSITIVE"
+	FullTransitive     CompatibilityMode = "FULL_TRANSITIVE"
+)
+
+// CompatibilityError describes a change between two versions of a schema that
+// breaks the required compatibility
+type CompatibilityError struct {
+	// Path is a JSON path to the changed value in the payloads, e.g. "$.lines[*].sku"
+	Path    string
+	Message string
+	// Version is the index of the previous version the change breaks compatibility
+	// with, as passed to CheckCompatibilityHistory
+	Version int
+}
+
+func (e CompatibilityError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// CheckCompatibility reports the changes from old to new that break the
+// compatibility mode: a new required property, a type narrowed, a format added or
+// changed or a property added to an object open to any other property break
+// backward compatibility, a required property removed, a type widened or a property
+// removed from an open object break forward compatibility. An empty result means
+// the schemas are compatible. Transitive modes behave like their plain
+// counterpart, an unknown mode is reported as an error.
+func CheckCompatibility(old, new Schema, mode CompatibilityMode) []CompatibilityError {
+	return CheckCompatibilityHistory([]Schema{old}, new, mode)
+}
+
+// CheckCompatibilityHistory reports the changes breaking the compatibility mode
+// between the previous versions of a schema, oldest first, and the new one. The
+// transitive modes check every previous version, the others the latest one only.
+func CheckCompatibilityHistory(previous []Schema, new Schema, mode CompatibilityMode) []CompatibilityError {
+	backward, forward, transitive, ok := mode.directions()
+	if !ok {
+		return []CompatibilityError{{Path: "$", Message: fmt.Sprintf("unknown compatibility mode %q", string(mode))}}
+	}
+
+	var errs []CompatibilityError
+	for i := len(previous) - 1; i >= 0; i-- {
+		c := &compatibilityChecker{
+			old:      previous[i],
+			new:      new,
+			backward: backward,
+			forward:  forward,
+			version:  i,
+			visited:  map[[2]string]bool{},
+		}
+		c.compare(rootProperty(previous[i]), rootProperty(new), "$")
+		errs = append(errs, c.errors...)
+
+		if !transitive {
+			break
+		}
+	}
+
+	return errs
+}
+
+// directions returns whether the mode requires backward and forward compatibility
+// and whether it is transitive, or false for an unknown mode
+func (mode CompatibilityMode) directions() (bool, bool, bool, bool) {
+	switch mode {
+	case Backward:
+		return true, false, false, true
+	case Forward:
+		return false, true, false, true
+	case Full:
+		return true, true, false, true
+	case BackwardTransitive:
+		return true, false, true, true
+	case ForwardTransitive:
+		return false, true, true, true
+	case FullTransitive:
+		return true, true, true, true
+	}
+	return false, false, false, false
+}
+
+// compatibilityChecker compares two versions of a schema collecting the changes
+// that break the required directions
+type compatibilityChecker struct {
+	old, new          Schema
+	backward, forward bool
+	version           int
+	// visited holds the pairs of schemas already compared where one of them is a
+	// reference, which ends the comparison of recursive types
+	visited map[[2]string]bool
+	errors  []CompatibilityError
+}
+
+// report records a change that breaks backward compatibility when it narrows what
+// is valid, forward compatibility when it widens it, or both
+func (c *compatibilityChecker) report(path string, narrows, widens bool, format string, args ...interface{}) {
+	if (narrows && c.backward) || (widens && c.forward) {
+		c.errors = append(c.errors, CompatibilityError{Path: path, Message: fmt.Sprintf(format, args...), Version: c.version})
+	}
+}
+
+// compare compares the old and the new schema of the values at path
+func (c *compatibilityChecker) compare(old, new PropertyDefinition, path string) {
+	if refersToDefinition(old) || refersToDefinition(new) {
+		key := [2]string{comparisonKey(old), comparisonKey(new)}
+		if c.visited[key] {
+			return
+		}
+		c.visited[key] = true
+	}
+	old = resolveForComparison(old, c.old.Definitions)
+	new = resolveForComparison(new, c.new.Definitions)
+
+	// Nothing else is compared when one side allows no value at all
+	if oldNone, newNone := rejectsAll(old), rejectsAll(new); oldNone || newNone {
+		switch {
+		case newNone && !oldNone:
+			c.report(path, true, false, "no value is allowed any more")
+		case oldNone && !newNone:
+			c.report(path, false, true, "values are allowed where none were")
+		}
+		return
+	}
+	if (old.Not != nil || new.Not != nil) && !reflect.DeepEqual(withoutDescriptions(notSchemas(old)), withoutDescriptions(notSchemas(new))) {
+		c.report(path, true, true, "not changed")
+	}
+
+	if len(old.AnyOf) > 0 || len(new.AnyOf) > 0 {
+		if !reflect.DeepEqual(withoutDescriptions(old.AnyOf), withoutDescriptions(new.AnyOf)) {
+			c.report(path, true, true, "anyOf changed")
+		}
+		return
+	}
+
+	c.compareTypes(old.Type, new.Type, path)
+	c.compareFormats(old.Format, new.Format, path)
+	c.compareEnums(old.Enum, new.Enum, path)
+	c.compareBound(old.Minimum, new.Minimum, true, "minimum", path)
+	c.compareBound(old.Maximum, new.Maximum, false, "maximum", path)
+	c.compareBound(old.ExclusiveMinimum, new.ExclusiveMinimum, true, "exclusiveMinimum", path)
+	c.compareBound(old.ExclusiveMaximum, new.ExclusiveMaximum, false, "exclusiveMaximum", path)
+	c.compareMultipleOf(old.MultipleOf, new.MultipleOf, path)
+	c.compareBound(intBound(old.MinLength), intBound(new.MinLength), true, "minLength", path)
+	c.compareBound(intBound(old.MaxLength), intBound(new.MaxLength), false, "maxLength", path)
+	c.compareBound(intBound(old.MinItems), intBound(new.MinItems), true, "minItems", path)
+	c.compareBound(intBound(old.MaxItems), intBound(new.MaxItems), false, "maxItems", path)
+	if old.Pattern != new.Pattern {
+		c.report(path, new.Pattern != "", old.Pattern != "", "pattern changed from %q to %q", old.Pattern, new.Pattern)
+	}
+	if old.UniqueItems != new.UniqueItems {
+		c.report(path, new.UniqueItems, old.UniqueItems, "uniqueItems changed from %t to %t", old.UniqueItems, new.UniqueItems)
+	}
+
+	c.compareRequired(old.Required, new.Required, path)
+	c.compareProperties(old, new, path)
+
+	// Tuple elements missing on one side are checked by the items of that side
+	for i := 0; i < len(old.PrefixItems) || i < len(new.PrefixItems); i++ {
+		c.compareSubschemas(tupleElement(old, i), tupleElement(new, i), fmt.Sprintf("%s[%d]", path, i))
+	}
+	c.compareSubschemas(old.Items, new.Items, path+"[*]")
+	c.compareSubschemas(old.AdditionalProperties, new.AdditionalProperties, path+".*")
+	c.compareSubschemas(old.PropertyNames, new.PropertyNames, path+".*~")
+}
+
+// compareProperties compares the properties of both versions. A property only one
+// of them declares is compared with the additionalProperties of the other, which
+// validate it there. Without additionalProperties any value is allowed, so adding
+// an optional property narrows what is valid unless it accepts any value too.
+func (c *compatibilityChecker) compareProperties(old, new PropertyDefinition, path string) {
+	names := make([]string, 0, len(old.Properties)+len(new.Properties))
+	for name := range old.Properties {
+		names = append(names, name)
+	}
+	for name := range new.Properties {
+		if _, ok := old.Properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldProperty, inOld := old.Properties[name]
+		newProperty, inNew := new.Properties[name]
+		switch {
+		case inOld && inNew:
+			c.compare(oldProperty, newProperty, jsonPathChild(path, name))
+		case inNew:
+			c.compareSubschemas(old.AdditionalProperties, &newProperty, jsonPathChild(path, name))
+		default:
+			c.compareSubschemas(&oldProperty, new.AdditionalProperties, jsonPathChild(path, name))
+		}
+	}
+}
+
+// compareSubschemas compares the schemas of a keyword such as items. A schema missing
+// on one side allows any value, so adding one narrows what is valid.
+func (c *compatibilityChecker) compareSubschemas(old, new *PropertyDefinition, path string) {
+	if old == nil && new == nil {
+		return
+	}
+
+	var oldSchema, newSchema PropertyDefinition
+	if old != nil {
+		oldSchema = *old
+	}
+	if new != nil {
+		newSchema = *new
+	}
+	c.compare(oldSchema, newSchema, path)
+}
+
+// tupleElement returns the schema of the element of an array at index i, taken from
+// its prefixItems or else from its items
+func tupleElement(p PropertyDefinition, i int) *PropertyDefinition {
+	if i < len(p.PrefixItems) {
+		return &p.PrefixItems[i]
+	}
+	return p.Items
+}
+
+// notSchemas returns the schema of the not keyword of a property as a list, empty
+// without one
+func notSchemas(p PropertyDefinition) []PropertyDefinition {
+	if p.Not == nil {
+		return nil
+	}
+	return []PropertyDefinition{*p.Not}
+}
+
+// withoutDescriptions returns copies of the schemas without their descriptions, which
+// don't change what is valid
+func withoutDescriptions(schemas []PropertyDefinition) []PropertyDefinition {
+	stripped := make([]PropertyDefinition, len(schemas))
+	for i, schema := range schemas {
+		stripped[i] = schema.transform(func(p PropertyDefinition) PropertyDefinition {
+			p.Description = ""
+			return p
+		})
+	}
+	return stripped
+}
+
+// refersToDefinition reports whether a property is a reference or a nullable
+// reference, which resolveForComparison replaces by a definition
+func refersToDefinition(property PropertyDefinition) bool {
+	return property.Ref != "" || isNullableRef(property)
+}
+
+// isNullableRef reports whether a property is anyOf a reference and the null type,
+// as written for pointers by WithNullablePointers
+func isNullableRef(property PropertyDefinition) bool {
+	return len(property.AnyOf) == 2 && property.AnyOf[0].Ref != "" && reflect.DeepEqual(property.AnyOf[1], PropertyDefinition{Type: typeOf(typeNull)})
+}
+
+// comparisonKey identifies a schema among the pairs compared. A schema written in
+// place is identified by its content, so that the same reference compared with
+// different schemas, e.g. two structs inlined where a definition was used, is
+// compared with each of them.
+func comparisonKey(property PropertyDefinition) string {
+	if property.Ref != "" && len(property.AnyOf) == 0 {
+		return property.Ref
+	}
+	encoded, err := json.Marshal(property)
+	if err != nil {
+		return fmt.Sprintf("%#v", property)
+	}
+	return string(encoded)
+}
+
+// resolveForComparison replaces a local reference by the definition it points to, and
+// a nullable reference, i.e. anyOf a reference and the null type, by the definition
+// accepting null
+func resolveForComparison(property PropertyDefinition, definitions map[string]PropertyDefinition) PropertyDefinition {
+	if isNullableRef(property) {
+		resolved := resolveForComparison(property.AnyOf[0], definitions)
+		makeNullable(&resolved)
+		return resolved
+	}
+
+	if !strings.HasPrefix(property.Ref, defsRefPrefix) {
+		return property
+	}
+	def, ok := definitions[unescapePointer(strings.TrimPrefix(property.Ref, defsRefPrefix))]
+	if !ok {
+		return property
+	}
+	return def
+}
+
+// compareTypes reports the types that are no longer accepted and the new ones. An
+// integer is a number, so changing one into the other only breaks one direction.
+func (c *compatibilityChecker) compareTypes(old, new SchemaType, path string) {
+	narrowed := !acceptsTypes(new, old)
+	widened := !acceptsTypes(old, new)
+	if !narrowed && !widened {
+		return
+	}
+
+	describe := func(t SchemaType) string {
+		if len(t) == 0 {
+			return "any"
+		}
+		return t.String()
+	}
+
+	switch {
+	case narrowed && widened:
+		c.report(path, true, true, "type changed from %s to %s", describe(old), describe(new))
+	case narrowed:
+		c.report(path, true, false, "type narrowed from %s to %s", describe(old), describe(new))
+	default:
+		c.report(path, false, true, "type widened from %s to %s", describe(old), describe(new))
+	}
+}
+
+// acceptsTypes reports whether every value of the types of other is of one of the
+// types of t. No type accepts any value.
+func acceptsTypes(t, other SchemaType) bool {
+	if len(t) == 0 {
+		return true
+	}
+	if len(other) == 0 {
+		return false
+	}
+	for _, name := range other {
+		if !t.Includes(name) && !(name == "integer" && t.Includes("number")) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareFormats reports an added, removed or changed format
+func (c *compatibilityChecker) compareFormats(old, new, path string) {
+	switch {
+	case old == new:
+	case old == "":
+		c.report(path, true, false, "format %q added", new)
+	case new == "":
+		c.report(path, false, true, "format %q removed", old)
+	default:
+		c.report(path, true, true, "format changed from %q to %q", old, new)
+	}
+}
+
+// compareEnums reports the values that are no longer allowed and the new ones
+func (c *compatibilityChecker) compareEnums(old, new []interface{}, path string) {
+	switch {
+	case len(old) == 0 && len(new) == 0:
+	case len(old) == 0:
+		c.report(path, true, false, "values restricted to %s", encodeValues(new))
+	case len(new) == 0:
+		c.report(path, false, true, "values no longer restricted to %s", encodeValues(old))
+	default:
+		if removed := missingValues(old, new); len(removed) > 0 {
+			c.report(path, true, false, "values %s no longer allowed", encodeValues(removed))
+		}
+		if added := missingValues(new, old); len(added) > 0 {
+			c.report(path, false, true, "values %s added", encodeValues(added))
+		}
+	}
+}
+
+// missingValues returns the values of a that aren't in b
+func missingValues(a, b []interface{}) []interface{} {
+	var missing []interface{}
+	for _, value := range a {
+		if !containsValue(b, value) {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
+
+// encodeValues writes enum values for messages, e.g. ["open","closed"]
+func encodeValues(values []interface{}) string {
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		encoded[i] = encodeValue(value)
+	}
+	return "[" + strings.Join(encoded, ",") + "]"
+}
+
+// compareBound reports a lower bound raised or an upper bound lowered, which rejects
+// values that were valid, and the opposite changes, which accept new values
+func (c *compatibilityChecker) compareBound(old, new *float64, lower bool, keyword, path string) {
+	switch {
+	case old == nil && new == nil:
+	case old == nil:
+		c.report(path, true, false, "%s %v added", keyword, *new)
+	case new == nil:
+		c.report(path, false, true, "%s %v removed", keyword, *old)
+	case *old != *new:
+		stricter := (*new > *old) == lower
+		c.report(path, stricter, !stricter, "%s changed from %v to %v", keyword, *old, *new)
+	}
+}
+
+// compareMultipleOf reports a multipleOf that rejects values that were valid, e.g.
+// changed from 0.01 to 0.1, or that accepts new values, e.g. changed from 0.1 to 0.01
+func (c *compatibilityChecker) compareMultipleOf(old, new *float64, path string) {
+	switch {
+	case old == nil && new == nil:
+	case old == nil:
+		c.report(path, true, false, "multipleOf %v added", *new)
+	case new == nil:
+		c.report(path, false, true, "multipleOf %v removed", *old)
+	case *old != *new:
+		narrows := !isMultipleOf(json.Number(strconv.FormatFloat(*old, 'g', -1, 64)), *new)
+		widens := !isMultipleOf(json.Number(strconv.FormatFloat(*new, 'g', -1, 64)), *old)
+		c.report(path, narrows, widens, "multipleOf changed from %v to %v", *old, *new)
+	}
+}
+
+// intBound converts an integer keyword for compareBound
+func intBound(value *int) *float64 {
+	if value == nil {
+		return nil
+	}
+	f := float64(*value)
+	return &f
+}
+
+// compareRequired reports the properties that became required and those that no longer are
+func (c *compatibilityChecker) compareRequired(old, new []string, path string) {
+	for _, name := range new {
+		if !containsString(old, name) {
+			c.report(jsonPathChild(path, name), true, false, "new required property %q", name)
+		}
+	}
+	for _, name := range old {
+		if !containsString(new, name) {
+			c.report(jsonPathChild(path, name), false, true, "required property %q removed", name)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonPathIdentifier matches the property names written with dot notation in JSON paths
+var jsonPathIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonPathChild returns the JSON path of a property, e.g. "$.tags.event_id" or "$['meta-data']"
+func jsonPathChild(path, name string) string {
+	if jsonPathIdentifier.MatchString(name) {
+		return path + "." + name
+	}
+	return path + "['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(name) + "']"
+}
@@ -0,0 +1,409 @@
+package schematic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type CompatLine struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+	Price    float64
+}
+
+type CompatOrderV1 struct {
+	ID        string       `json:"id"`
+	Total     float64      `json:"total"`
+	CreatedAt string       `json:"created_at" jsonschema:"format=date-time"`
+	Note      string       `json:"note,omitempty"`
+	Lines     []CompatLine `json:"lines"`
+}
+
+type CompatLineV2 struct {
+	SKU      int `json:"sku"`
+	Quantity int `json:"quantity"`
+	Price    float64
+}
+
+type CompatOrderV2 struct {
+	Total     int            `json:"total"`
+	CreatedAt string         `json:"created_at" jsonschema:"format=date"`
+	Note      string         `json:"note"`
+	Lines     []CompatLineV2 `json:"lines"`
+}
+
+func compatibilityMessages(errs []CompatibilityError) []string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return messages
+}
+
+func TestCheckCompatibilitySameSchema(t *testing.T) {
+	schema := GenerateSchema(CompatOrderV1{}, "Order", "")
+
+	for _, mode := range []CompatibilityMode{Backward, Forward, Full, FullTransitive} {
+		require.Empty(t, CheckCompatibility(schema, schema, mode))
+	}
+}
+
+func TestCheckCompatibilityBackward(t *testing.T) {
+	old := GenerateSchema(CompatOrderV1{}, "Order", "")
+	new := GenerateSchema(CompatOrderV2{}, "Order", "")
+
+	require.ElementsMatch(t, []string{
+		`$.note: new required property "note"`,
+		`$.created_at: format changed from "date-time" to "date"`,
+		`$.lines[*].sku: type changed from string to integer`,
+		`$.total: type narrowed from number to integer`,
+	}, compatibilityMessages(CheckCompatibility(old, new, Backward)))
+}
+
+func TestCheckCompatibilityForward(t *testing.T) {
+	old := GenerateSchema(CompatOrderV1{}, "Order", "")
+	new := GenerateSchema(CompatOrderV2{}, "Order", "")
+
+	require.ElementsMatch(t, []string{
+		`$.id: required property "id" removed`,
+		`$.id: type widened from string to any`,
+		`$.created_at: format changed from "date-time" to "date"`,
+		`$.lines[*].sku: type changed from string to integer`,
+	}, compatibilityMessages(CheckCompatibility(old, new, Forward)))
+}
+
+func TestCheckCompatibilityFull(t *testing.T) {
+	old := GenerateSchema(CompatOrderV1{}, "Order", "")
+	new := GenerateSchema(CompatOrderV2{}, "Order", "")
+
+	// Changes breaking both directions are reported once
+	require.ElementsMatch(t, []string{
+		`$.note: new required property "note"`,
+		`$.id: required property "id" removed`,
+		`$.id: type widened from string to any`,
+		`$.created_at: format changed from "date-time" to "date"`,
+		`$.lines[*].sku: type changed from string to integer`,
+		`$.total: type narrowed from number to integer`,
+	}, compatibilityMessages(CheckCompatibility(old, new, Full)))
+}
+
+func TestCheckCompatibilityConstraints(t *testing.T) {
+	minimum := 1.0
+	raised := 5.0
+	old := Schema{
+		Type: "object",
+		Properties: map[string]PropertyDefinition{
+			"status":   {Type: typeOf("string"), Enum: []interface{}{"open", "closed"}},
+			"quantity": {Type: typeOf("integer"), Minimum: &minimum},
+			"meta-data": {
+				Type:                 typeOf("object"),
+				AdditionalProperties: &PropertyDefinition{Type: typeOf("string")},
+			},
+		},
+	}
+	new := Schema{
+		Type: "object",
+		Properties: map[string]PropertyDefinition{
+			"status":   {Type: typeOf("string"), Enum: []interface{}{"open", "cancelled"}},
+			"quantity": {Type: typeOf("integer"), Minimum: &raised},
+			"meta-data": {
+				Type:                 typeOf("object"),
+				AdditionalProperties: &PropertyDefinition{Type: SchemaType{"string", typeNull}},
+			},
+		},
+	}
+
+	require.ElementsMatch(t, []string{
+		`$.status: values ["closed"] no longer allowed`,
+		`$.quantity: minimum changed from 1 to 5`,
+	}, compatibilityMessages(CheckCompatibility(old, new, Backward)))
+
+	require.ElementsMatch(t, []string{
+		`$.status: values ["cancelled"] added`,
+		`$['meta-data'].*: type widened from string to string or null`,
+	}, compatibilityMessages(CheckCompatibility(old, new, Forward)))
+}
+
+func TestCheckCompatibilityKeywords(t *testing.T) {
+	number := func(f float64) *float64 { return &f }
+	length := func(n int) *int { return &n }
+	str := PropertyDefinition{Type: typeOf("string")}
+	integer := PropertyDefinition{Type: typeOf("integer")}
+	closed := falseSchema()
+
+	tests := []struct {
+		name              string
+		old, new          PropertyDefinition
+		backward, forward []string
+	}{
+		{
+			name:     "exclusiveMinimum added",
+			old:      PropertyDefinition{Type: typeOf("number")},
+			new:      PropertyDefinition{Type: typeOf("number"), ExclusiveMinimum: number(0)},
+			backward: []string{"$.value: exclusiveMinimum 0 added"},
+		},
+		{
+			name:     "exclusiveMaximum tightened",
+			old:      PropertyDefinition{Type: typeOf("number"), ExclusiveMaximum: number(100)},
+			new:      PropertyDefinition{Type: typeOf("number"), ExclusiveMaximum: number(10)},
+			backward: []string{"$.value: exclusiveMaximum changed from 100 to 10"},
+		},
+		{
+			name:    "exclusiveMaximum loosened",
+			old:     PropertyDefinition{Type: typeOf("number"), ExclusiveMaximum: number(10)},
+			new:     PropertyDefinition{Type: typeOf("number"), ExclusiveMaximum: number(100)},
+			forward: []string{"$.value: exclusiveMaximum changed from 10 to 100"},
+		},
+		{
+			name:     "multipleOf added",
+			old:      PropertyDefinition{Type: typeOf("number")},
+			new:      PropertyDefinition{Type: typeOf("number"), MultipleOf: number(0.01)},
+			backward: []string{"$.value: multipleOf 0.01 added"},
+		},
+		{
+			name:     "multipleOf coarser",
+			old:      PropertyDefinition{Type: typeOf("number"), MultipleOf: number(0.01)},
+			new:      PropertyDefinition{Type: typeOf("number"), MultipleOf: number(0.1)},
+			backward: []string{"$.value: multipleOf changed from 0.01 to 0.1"},
+		},
+		{
+			name:    "multipleOf finer",
+			old:     PropertyDefinition{Type: typeOf("number"), MultipleOf: number(0.1)},
+			new:     PropertyDefinition{Type: typeOf("number"), MultipleOf: number(0.01)},
+			forward: []string{"$.value: multipleOf changed from 0.1 to 0.01"},
+		},
+		{
+			name:     "multipleOf unrelated",
+			old:      PropertyDefinition{Type: typeOf("number"), MultipleOf: number(0.25)},
+			new:      PropertyDefinition{Type: typeOf("number"), MultipleOf: number(0.1)},
+			backward: []string{"$.value: multipleOf changed from 0.25 to 0.1"},
+			forward:  []string{"$.value: multipleOf changed from 0.25 to 0.1"},
+		},
+		{
+			name:     "uniqueItems turned on",
+			old:      PropertyDefinition{Type: typeOf(typeArray), Items: &str},
+			new:      PropertyDefinition{Type: typeOf(typeArray), Items: &str, UniqueItems: true},
+			backward: []string{"$.value: uniqueItems changed from false to true"},
+		},
+		{
+			name:    "uniqueItems turned off",
+			old:     PropertyDefinition{Type: typeOf(typeArray), Items: &str, UniqueItems: true},
+			new:     PropertyDefinition{Type: typeOf(typeArray), Items: &str},
+			forward: []string{"$.value: uniqueItems changed from true to false"},
+		},
+		{
+			name:     "propertyNames added",
+			old:      PropertyDefinition{Type: typeOf("object")},
+			new:      PropertyDefinition{Type: typeOf("object"), PropertyNames: &PropertyDefinition{Type: typeOf("string"), Pattern: "^[0-9]+$"}},
+			backward: []string{"$.value.*~: type narrowed from any to string", `$.value.*~: pattern changed from "" to "^[0-9]+$"`},
+		},
+		{
+			name:     "propertyNames changed",
+			old:      PropertyDefinition{Type: typeOf("object"), PropertyNames: &PropertyDefinition{Type: typeOf("string"), MaxLength: length(10)}},
+			new:      PropertyDefinition{Type: typeOf("object"), PropertyNames: &PropertyDefinition{Type: typeOf("string"), MaxLength: length(5)}},
+			backward: []string{"$.value.*~: maxLength changed from 10 to 5"},
+		},
+		{
+			name:     "items added",
+			old:      PropertyDefinition{Type: typeOf(typeArray)},
+			new:      PropertyDefinition{Type: typeOf(typeArray), Items: &integer},
+			backward: []string{"$.value[*]: type narrowed from any to integer"},
+		},
+		{
+			name:    "additionalProperties removed",
+			old:     PropertyDefinition{Type: typeOf("object"), AdditionalProperties: &str},
+			new:     PropertyDefinition{Type: typeOf("object")},
+			forward: []string{"$.value.*: type widened from string to any"},
+		},
+		{
+			name:     "property added under additionalProperties",
+			old:      PropertyDefinition{Type: typeOf("object"), AdditionalProperties: &str},
+			new:      PropertyDefinition{Type: typeOf("object"), AdditionalProperties: &str, Properties: map[string]PropertyDefinition{"count": integer}},
+			backward: []string{"$.value.count: type changed from string to integer"},
+			forward:  []string{"$.value.count: type changed from string to integer"},
+		},
+		{
+			name: "property removed under additionalProperties",
+			old:  PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"count": integer}, AdditionalProperties: &integer},
+			new:  PropertyDefinition{Type: typeOf("object"), AdditionalProperties: &integer},
+		},
+		{
+			name:     "property added to an open object",
+			old:      PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str}},
+			new:      PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str, "note": str}},
+			backward: []string{"$.value.note: type narrowed from any to string"},
+		},
+		{
+			name:    "property removed from an open object",
+			old:     PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str, "note": str}},
+			new:     PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str}},
+			forward: []string{"$.value.note: type widened from string to any"},
+		},
+		{
+			name: "property accepting any value added to an open object",
+			old:  PropertyDefinition{Type: typeOf("object")},
+			new:  PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"payload": {}}},
+		},
+		{
+			name:     "tuple element changed",
+			old:      PropertyDefinition{Type: typeOf(typeArray), PrefixItems: []PropertyDefinition{str, integer}},
+			new:      PropertyDefinition{Type: typeOf(typeArray), PrefixItems: []PropertyDefinition{str, str}, Items: &integer},
+			backward: []string{"$.value[1]: type changed from integer to string", "$.value[*]: type narrowed from any to integer"},
+			forward:  []string{"$.value[1]: type changed from integer to string"},
+		},
+		{
+			name:     "additionalProperties false added",
+			old:      PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str}},
+			new:      PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str}, AdditionalProperties: &closed},
+			backward: []string{"$.value.*: no value is allowed any more"},
+		},
+		{
+			name:    "property added to a closed object",
+			old:     PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str}, AdditionalProperties: &closed},
+			new:     PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str, "note": str}, AdditionalProperties: &closed},
+			forward: []string{"$.value.note: values are allowed where none were"},
+		},
+		{
+			name:     "property removed from a closed object",
+			old:      PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str, "note": str}, AdditionalProperties: &closed},
+			new:      PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": str}, AdditionalProperties: &closed},
+			backward: []string{"$.value.note: no value is allowed any more"},
+		},
+		{
+			name:     "not changed",
+			old:      PropertyDefinition{Type: typeOf("string"), Not: &PropertyDefinition{Enum: []interface{}{"a"}}},
+			new:      PropertyDefinition{Type: typeOf("string"), Not: &PropertyDefinition{Enum: []interface{}{"b"}}},
+			backward: []string{"$.value: not changed"},
+			forward:  []string{"$.value: not changed"},
+		},
+		{
+			name: "anyOf descriptions changed",
+			old:  PropertyDefinition{AnyOf: []PropertyDefinition{{Type: typeOf("string"), Description: "Code"}, integer}},
+			new:  PropertyDefinition{AnyOf: []PropertyDefinition{{Type: typeOf("string"), Description: "Reference"}, integer}},
+		},
+		{
+			name:     "anyOf changed",
+			old:      PropertyDefinition{AnyOf: []PropertyDefinition{str, integer}},
+			new:      PropertyDefinition{AnyOf: []PropertyDefinition{str}},
+			backward: []string{"$.value: anyOf changed"},
+			forward:  []string{"$.value: anyOf changed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := Schema{Type: "object", Properties: map[string]PropertyDefinition{"value": tt.old}}
+			new := Schema{Type: "object", Properties: map[string]PropertyDefinition{"value": tt.new}}
+
+			require.ElementsMatch(t, tt.backward, compatibilityMessages(CheckCompatibility(old, new, Backward)))
+			require.ElementsMatch(t, tt.forward, compatibilityMessages(CheckCompatibility(old, new, Forward)))
+		})
+	}
+}
+
+type CompatShipment struct {
+	Line CompatLine `json:"line"`
+}
+
+func TestCheckCompatibilityNullableReference(t *testing.T) {
+	new := GenerateSchema(CompatShipment{}, "Shipment", "")
+	old := GenerateSchema(CompatShipment{}, "Shipment", "")
+	line := old.Properties["line"]
+	makeNullable(&line)
+	old.Properties["line"] = line
+
+	// The reference is resolved and compared with the nullable one
+	require.Empty(t, CheckCompatibility(old, new, Forward))
+	require.Equal(t, []string{
+		`$.line: type narrowed from object or null to object`,
+	}, compatibilityMessages(CheckCompatibility(old, new, Backward)))
+}
+
+func TestCheckCompatibilityHistory(t *testing.T) {
+	v1 := GenerateSchema(CompatOrderV1{}, "Order", "")
+	v2 := GenerateSchema(CompatOrderV1{}, "Order", "")
+	v2.Required = []string{"total"}
+	v3 := v2
+
+	// v3 is v2, only the transitive mode compares it with v1
+	require.Empty(t, CheckCompatibilityHistory([]Schema{v1, v2}, v3, Forward))
+
+	errs := CheckCompatibilityHistory([]Schema{v1, v2}, v3, ForwardTransitive)
+	require.ElementsMatch(t, []string{
+		`$.id: required property "id" removed`,
+		`$.created_at: required property "created_at" removed`,
+	}, compatibilityMessages(errs))
+	for _, err := range errs {
+		require.Equal(t, 0, err.Version)
+	}
+}
+
+type CompatCategory struct {
+	Name     string           `json:"name"`
+	Code     string           `json:"code"`
+	Children []CompatCategory `json:"children"`
+}
+
+type CompatNode struct {
+	Name   string      `json:"name"`
+	Parent *CompatNode `json:"parent"`
+}
+
+func TestCheckCompatibilityRecursiveDefinition(t *testing.T) {
+	old := GenerateSchema(CompatCategory{}, "Category", "")
+	new := GenerateSchema(CompatCategory{}, "Category", "")
+
+	require.Empty(t, CheckCompatibility(old, new, Full))
+
+	// Nullable pointers reference the definition inside anyOf
+	old = GenerateSchema(CompatNode{}, "Node", "", WithNullablePointers())
+	new = GenerateSchema(CompatNode{}, "Node", "", WithNullablePointers())
+
+	require.Empty(t, CheckCompatibility(old, new, Full))
+}
+
+type CompatAddress struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+	Zip    string `json:"zip"`
+}
+
+type CompatBillingAddress struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
+type CompatShippingAddress struct {
+	Street int    `json:"street"`
+	City   string `json:"city"`
+}
+
+type CompatCustomerV1 struct {
+	Billing  CompatAddress `json:"billing"`
+	Shipping CompatAddress `json:"shipping"`
+}
+
+type CompatCustomerV2 struct {
+	Billing  CompatBillingAddress  `json:"billing"`
+	Shipping CompatShippingAddress `json:"shipping"`
+}
+
+func TestCheckCompatibilitySharedDefinitionInlined(t *testing.T) {
+	old := GenerateSchema(CompatCustomerV1{}, "Customer", "")
+	new := GenerateSchema(CompatCustomerV2{}, "Customer", "", WithDefinitionPolicy(InlineDefinitions))
+
+	// The definition used by both properties is compared with each inlined struct
+	require.ElementsMatch(t, []string{
+		`$.billing.zip: required property "zip" removed`,
+		`$.billing.zip: type widened from string to any`,
+		`$.shipping.street: type changed from string to integer`,
+		`$.shipping.zip: required property "zip" removed`,
+		`$.shipping.zip: type widened from string to any`,
+	}, compatibilityMessages(CheckCompatibility(old, new, Full)))
+}
+
+func TestCheckCompatibilityUnknownMode(t *testing.T) {
+	require.Equal(t, []string{`$: unknown compatibility mode "SIDEWAYS"`}, compatibilityMessages(CheckCompatibility(Schema{}, Schema{}, "SIDEWAYS")))
+	require.Len(t, CheckCompatibilityHistory(nil, Schema{}, ""), 1)
+}
@@ -0,0 +1,386 @@
+package schematic
+
+import (
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefinitionNaming names the $defs entry of a named Go type
+type DefinitionNaming func(t reflect.Type) string
+
+var (
+	// ShortDefinitionNames names definitions by the type name alone, e.g. "Address".
+	// Types sharing a name are disambiguated by their package.
+	ShortDefinitionNames DefinitionNaming = shortTypeName
+
+	// PackageDefinitionNames qualifies definitions by their package name, e.g. "billing.Address"
+	PackageDefinitionNames DefinitionNaming = packageTypeName
+
+	// FullPathDefinitionNames qualifies definitions by their full package path,
+	// e.g. "github.com/acme/billing.Address"
+	FullPathDefinitionNames DefinitionNaming = fullPathTypeName
+)
+
+// definitionRefPrefix starts the placeholder references handed out while the
+// definitions are built. They are replaced by the final names once all
+// definitions are known.
+const definitionRefPrefix = "schematic:definition:"
+
+// nullableRefSuffix marks placeholder references of nullable pointers
+const nullableRefSuffix = ":nullable"
+
+// DefinitionPolicy decides whether a struct type is placed in $defs and referenced,
+// or inlined wherever it is used. property is the schema of the type and uses the
+// number of places it is used in the schema, where the uses inside another
+// definition count once. Recursive types are always placed in $defs.
+type DefinitionPolicy func(t reflect.Type, property PropertyDefinition, uses int) bool
+
+var (
+	// NamedDefinitions places every named struct in $defs and inlines anonymous structs
+	NamedDefinitions DefinitionPolicy = func(t reflect.Type, _ PropertyDefinition, _ int) bool {
+		return t.Name() != ""
+	}
+
+	// ReusedDefinitions places the structs used more than once in $defs
+	ReusedDefinitions DefinitionPolicy = func(_ reflect.Type, _ PropertyDefinition, uses int) bool {
+		return uses > 1
+	}
+
+	// InlineDefinitions inlines every struct that isn't recursive
+	InlineDefinitions DefinitionPolicy = func(reflect.Type, PropertyDefinition, int) bool {
+		return false
+	}
+)
+
+// ThresholdDefinitions places the structs with more than threshold properties in $defs.
+// It is the default policy, with the Reflector's DefinitionThreshold.
+func ThresholdDefinitions(threshold int) DefinitionPolicy {
+	return func(_ reflect.Type, property PropertyDefinition, _ int) bool {
+		return len(property.Properties) > threshold
+	}
+}
+
+// definition is a $defs entry in the making
+type definition struct {
+	t reflect.Type
+	// ref is the placeholder reference to the definition
+	ref string
+	// owner is the closest named struct enclosing an anonymous struct and path
+	// the names of the fields leading from it to the anonymous struct
+	owner reflect.Type
+	path  string
+	// property is the schema of the type, valid once stored is set
+	property PropertyDefinition
+	stored   bool
+}
+
+// buildFrame is a struct being built and the name of the field it was reached through
+type buildFrame struct {
+	t     reflect.Type
+	field string
+}
+
+// definitionFor returns the definition of t, reserving it on first use. Anonymous
+// structs are named after the fields leading to them from the closest named struct
+// being built.
+func (ctx *schemaContext) definitionFor(t reflect.Type, name string) *definition {
+	if def, ok := ctx.definitions[t]; ok {
+		return def
+	}
+
+	def := &definition{
+		t:   t,
+		ref: definitionRefPrefix + strconv.Itoa(len(ctx.order)),
+	}
+
+	if t.Name() == "" {
+		fields := []string{name}
+		for i := len(ctx.building) - 1; i >= 0; i-- {
+			frame := ctx.building[i]
+			if frame.t.Name() != "" {
+				def.owner = frame.t
+				break
+			}
+			fields = append([]string{frame.field}, fields...)
+		}
+		def.path = strings.Join(fields, "")
+	}
+
+	ctx.definitions[t] = def
+	ctx.order = append(ctx.order, def)
+
+	return def
+}
+
+// definitionNames assigns every kept definition a unique name. Definitions start
+// out with the name given by the Reflector's DefinitionNaming; whenever several of
+// them share a name they all fall back to the package name and then to the full
+// package path. Types that even share their full path, like types declared in
+// functions, keep their name and are numbered in the order they were first
+// encountered. The names therefore don't depend on the traversal order.
+func (ctx *schemaContext) definitionNames(defs []*definition) map[*definition]string {
+	namings := []DefinitionNaming{ctx.reflector.definitionNaming(), PackageDefinitionNames, FullPathDefinitionNames}
+	levels := make([]int, len(defs))
+	names := make([]string, len(defs))
+
+	for {
+		groups := map[string][]int{}
+		for i, def := range defs {
+			names[i] = def.name(namings[levels[i]])
+			groups[names[i]] = append(groups[names[i]], i)
+		}
+
+		changed := false
+		for _, group := range groups {
+			if len(group) < 2 {
+				continue
+			}
+
+			// Types with the same full path can't be told apart by qualifying them
+			fullPaths := map[string]int{}
+			for _, i := range group {
+				fullPaths[defs[i].name(FullPathDefinitionNames)]++
+			}
+
+			for _, i := range group {
+				if levels[i] < len(namings)-1 && fullPaths[defs[i].name(FullPathDefinitionNames)] == 1 {
+					levels[i]++
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	taken := map[string]int{}
+	for _, name := range names {
+		taken[name]++
+	}
+	result := make(map[*definition]string, len(defs))
+	seen := map[string]bool{}
+	for i, def := range defs {
+		name := names[i]
+		if taken[name] > 1 && seen[name] {
+			suffix := 2
+			for taken[name+strconv.Itoa(suffix)] > 0 {
+				suffix++
+			}
+			name += strconv.Itoa(suffix)
+			taken[name]++
+		}
+		seen[names[i]] = true
+		result[def] = name
+	}
+
+	return result
+}
+
+// name returns the name of the definition using naming for named types
+func (def *definition) name(naming DefinitionNaming) string {
+	if def.t.Name() != "" {
+		return naming(def.t)
+	}
+
+	path := def.path
+	if path == "" {
+		path = "Anonymous"
+	}
+	if def.owner == nil {
+		return "Anonymous" + path
+	}
+	return naming(def.owner) + path
+}
+
+// definitionResolver replaces the placeholder references of a generation by
+// references to the definitions kept in $defs or by the inlined definitions
+type definitionResolver struct {
+	ctx     *schemaContext
+	names   map[*definition]string
+	inlined map[*definition]PropertyDefinition
+}
+
+// newResolver decides which definitions are kept, based on the DefinitionPolicy and
+// the uses of each definition in properties and in the other definitions, and names them
+func (ctx *schemaContext) newResolver(properties map[string]PropertyDefinition) *definitionResolver {
+	uses := map[*definition]int{}
+	count := func(p PropertyDefinition) PropertyDefinition {
+		if def, _, ok := ctx.lookupRef(p.Ref); ok {
+			uses[def]++
+		}
+		return p
+	}
+	for _, property := range properties {
+		property.transform(count)
+	}
+	for _, def := range ctx.order {
+		if def.stored {
+			def.property.transform(count)
+		}
+	}
+
+	policy := ctx.reflector.definitionPolicy()
+	var kept []*definition
+	for _, def := range ctx.order {
+		// Recursive types can only be described through a reference to themselves
+		if def.stored && (ctx.recursive[def.t] || ctx.roots[def.t] || policy(def.t, def.property, uses[def])) {
+			kept = append(kept, def)
+		}
+	}
+
+	return &definitionResolver{
+		ctx:     ctx,
+		names:   ctx.definitionNames(kept),
+		inlined: map[*definition]PropertyDefinition{},
+	}
+}
+
+// lookupRef returns the definition a placeholder reference points to and whether
+// the reference accepts null
+func (ctx *schemaContext) lookupRef(ref string) (*definition, bool, bool) {
+	if !strings.HasPrefix(ref, definitionRefPrefix) {
+		return nil, false, false
+	}
+
+	index, nullable := strings.CutSuffix(strings.TrimPrefix(ref, definitionRefPrefix), nullableRefSuffix)
+	i, err := strconv.Atoi(index)
+	if err != nil || i >= len(ctx.order) {
+		return nil, false, false
+	}
+
+	return ctx.order[i], nullable, true
+}
+
+// definitions returns the kept definitions keyed by their final names
+func (r *definitionResolver) definitions() map[string]PropertyDefinition {
+	definitions := make(map[string]PropertyDefinition, len(r.names))
+	for def, name := range r.names {
+		definitions[name] = r.resolve(def.property)
+	}
+	return definitions
+}
+
+// properties resolves the references within properties
+func (r *definitionResolver) properties(properties map[string]PropertyDefinition) map[string]PropertyDefinition {
+	resolved := make(map[string]PropertyDefinition, len(properties))
+	for name, property := range properties {
+		resolved[name] = r.resolve(property)
+	}
+	return resolved
+}
+
+// resolve replaces the placeholder references within a property
+func (r *definitionResolver) resolve(property PropertyDefinition) PropertyDefinition {
+	return property.transform(func(p PropertyDefinition) PropertyDefinition {
+		def, nullable, ok := r.ctx.lookupRef(p.Ref)
+		if !ok {
+			return p
+		}
+
+		if name, kept := r.names[def]; kept {
+			p.Ref = defsRefPrefix + escapePointer(name)
+		} else {
+			description := p.Description
+			p = r.inline(def).clone()
+			p.Description = description
+		}
+
+		if nullable {
+			makeNullable(&p)
+		}
+		return p
+	})
+}
+
+// inline returns the resolved schema of a definition that isn't kept. Definitions
+// that aren't kept are never recursive, so resolving them terminates.
+func (r *definitionResolver) inline(def *definition) PropertyDefinition {
+	if property, ok := r.inlined[def]; ok {
+		return property
+	}
+	property := r.resolve(def.property)
+	r.inlined[def] = property
+	return property
+}
+
+// qualifiedIdentifier matches a package qualified identifier within a type name,
+// e.g. the type arguments in "Page[github.com/acme/shop.Order]"
+var qualifiedIdentifier = regexp.MustCompile(`([^\[\]*,\s()]+)\.([^\[\]*,\s().]+)`)
+
+// typeArgumentReplacer spells out the type constructors used in type arguments so
+// that e.g. Page[Order] and Page[[]Order] keep distinct names
+var typeArgumentReplacer = strings.NewReplacer("[]", "Slice", "*", "Ptr")
+
+// unsafeNameCharacters matches the characters of instantiated generic type names,
+// such as brackets, commas and spaces, that some consumers reject in $ref URIs
+var unsafeNameCharacters = regexp.MustCompile(`[^\p{L}\p{N}_./-]+`)
+
+// shortTypeName returns the type name with the package paths of type arguments removed
+func shortTypeName(t reflect.Type) string {
+	return sanitizeTypeName(qualifyTypeName(t.Name(), func(pkgPath, name string) string {
+		return name
+	}))
+}
+
+// packageTypeName returns the type name qualified by its package name
+func packageTypeName(t reflect.Type) string {
+	name := qualifyTypeName(t.Name(), func(pkgPath, name string) string {
+		return packageName(pkgPath) + "." + name
+	})
+	if t.PkgPath() != "" {
+		name = packageName(t.PkgPath()) + "." + name
+	}
+	return sanitizeTypeName(name)
+}
+
+// fullPathTypeName returns the type name qualified by its full package path
+func fullPathTypeName(t reflect.Type) string {
+	if t.PkgPath() == "" {
+		return sanitizeTypeName(t.Name())
+	}
+	return sanitizeTypeName(t.PkgPath() + "." + t.Name())
+}
+
+// qualifyTypeName rewrites the package qualified identifiers in a type name, which
+// only occur in the type arguments of instantiated generic types
+func qualifyTypeName(name string, qualify func(pkgPath, name string) string) string {
+	return qualifiedIdentifier.ReplaceAllStringFunc(name, func(identifier string) string {
+		match := qualifiedIdentifier.FindStringSubmatch(identifier)
+		return qualify(match[1], match[2])
+	})
+}
+
+// sanitizeTypeName turns the name of an instantiated generic type into a name that
+// is safe to use in a $ref, e.g. "Envelope[Page[Order]]" into "Envelope_Page_Order"
+// and "Pair[string,[]Order]" into "Pair_string_SliceOrder". Other names are unchanged.
+func sanitizeTypeName(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+	name = unsafeNameCharacters.ReplaceAllString(typeArgumentReplacer.Replace(name), "_")
+	return strings.Trim(name, "_")
+}
+
+// packageName guesses the name of a package from its import path, skipping major
+// version suffixes such as "/v2"
+func packageName(pkgPath string) string {
+	name := path.Base(pkgPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		if parent := path.Dir(pkgPath); parent != "." {
+			name = path.Base(parent)
+		}
+	}
+	return name
+}
+
+// sameDefinition reports whether two definitions describe the same type. The
+// description of a definition is the name of the field that first referenced it, so
+// the same type used under different field names in two schemas only differs there.
+func sameDefinition(a, b PropertyDefinition) bool {
+	a.Description, b.Description = "", ""
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,437 @@
+package schematic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Draft identifies a JSON Schema draft by the URL of its meta-schema, which is
+// written to the $schema keyword
+type Draft string
+
+const (
+	Draft04     Draft = "http://json-schema.org/draft-04/schema#"
+	Draft06     Draft = "http://json-schema.org/draft-06/schema#"
+	Draft07     Draft = "http://json-schema.org/draft-07/schema#"
+	Draft201909 Draft = "https://json-schema.org/draft/2019-09/schema"
+	Draft202012 Draft = "https://json-schema.org/draft/2020-12/schema"
+)
+
+// defsRefPrefix starts the references to definitions in the generated schemas,
+// whatever the draft they are marshaled for
+const defsRefPrefix = "#/$defs/"
+
+// dialect holds the spelling of the keywords that differ between drafts. Schemas
+// are generated with the 2020-12 keywords and respelled when they are marshaled;
+// the keywords a draft doesn't have at all are translated or dropped by property.
+type dialect struct {
+	// id is the keyword holding the identifier of the schema
+	id string
+	// definitions is the keyword holding the definitions and refPrefix the start of
+	// the references to them
+	definitions string
+	refPrefix   string
+	// tupleItems spells prefixItems as an array of items followed by additionalItems
+	tupleItems bool
+	// booleanExclusive spells exclusiveMinimum and exclusiveMaximum as booleans
+	// turning minimum and maximum into exclusive bounds
+	booleanExclusive bool
+	// nullable spells the null type as the OpenAPI 3.0 nullable keyword
+	nullable bool
+	// singleItems spells prefixItems as a single items schema accepting any of the
+	// tuple elements, for OpenAPI 3.0 which has no tuples
+	singleItems bool
+	// noPropertyNames drops propertyNames, which draft-04 and OpenAPI 3.0 don't have,
+	// leaving the keys of maps unconstrained
+	noPropertyNames bool
+}
+
+var dialects = map[Draft]*dialect{
+	Draft04:     {id: "id", definitions: "definitions", refPrefix: "#/definitions/", tupleItems: true, booleanExclusive: true, noPropertyNames: true},
+	Draft06:     {id: "$id", definitions: "definitions", refPrefix: "#/definitions/", tupleItems: true},
+	Draft07:     {id: "$id", definitions: "definitions", refPrefix: "#/definitions/", tupleItems: true},
+	Draft201909: {id: "$id", definitions: "$defs", refPrefix: defsRefPrefix, tupleItems: true},
+	Draft202012: {id: "$id", definitions: "$defs", refPrefix: defsRefPrefix},
+}
+
+// DraftOf returns the draft declared by a $schema URL. The scheme and a trailing
+// "#" are ignored. Unknown URLs, including an empty one, are treated as Draft202012.
+func DraftOf(schemaURL string) Draft {
+	for draft := range dialects {
+		if normalizeSchemaURL(string(draft)) == normalizeSchemaURL(schemaURL) {
+			return draft
+		}
+	}
+	return Draft202012
+}
+
+func normalizeSchemaURL(schemaURL string) string {
+	schemaURL = strings.TrimPrefix(strings.TrimPrefix(schemaURL, "https://"), "http://")
+	return strings.TrimRight(schemaURL, "#/")
+}
+
+// Draft returns the draft the schema is written for, according to its $schema keyword
+func (s Schema) Draft() Draft {
+	return DraftOf(s.Schema)
+}
+
+// MarshalJSON writes the schema with the keywords of the draft named by its
+// $schema keyword, e.g. "definitions" instead of "$defs" for draft-07 and "id"
+// instead of "$id" for draft-04
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type schema Schema
+
+	d := dialects[s.Draft()]
+	out := struct {
+		schema
+		ID          string                         `json:"$id,omitempty"`
+		LegacyID    string                         `json:"id,omitempty"`
+		Type        *string                        `json:"type,omitempty"`
+		Properties  *map[string]PropertyDefinition `json:"properties,omitempty"`
+		Defs        map[string]PropertyDefinition  `json:"$defs,omitempty"`
+		Definitions map[string]PropertyDefinition  `json:"definitions,omitempty"`
+	}{schema: schema(s)}
+
+	if d.id == "id" {
+		out.LegacyID = s.ID
+	} else {
+		out.ID = s.ID
+	}
+
+	if !s.definitionsOnly {
+		properties := d.properties(s.Properties)
+		out.Type = &s.Type
+		out.Properties = &properties
+	}
+	if s.AdditionalProperties != nil {
+		additional := d.property(*s.AdditionalProperties)
+		out.AdditionalProperties = &additional
+	}
+	if d.definitions == "definitions" {
+		out.Definitions = d.properties(s.Definitions)
+	} else {
+		out.Defs = d.properties(s.Definitions)
+	}
+
+	return json.Marshal(out)
+}
+
+// properties returns a copy of the properties to be marshaled with the dialect
+func (d *dialect) properties(properties map[string]PropertyDefinition) map[string]PropertyDefinition {
+	if properties == nil {
+		return nil
+	}
+
+	converted := make(map[string]PropertyDefinition, len(properties))
+	for name, property := range properties {
+		converted[name] = d.property(property)
+	}
+	return converted
+}
+
+// property returns a copy of the property to be marshaled with the dialect
+func (d *dialect) property(property PropertyDefinition) PropertyDefinition {
+	return property.transform(func(p PropertyDefinition) PropertyDefinition {
+		p.dialect = d
+		p.Ref = replaceRefPrefix(p.Ref, defsRefPrefix, d.refPrefix)
+		if d.singleItems && len(p.PrefixItems) > 0 {
+			p = singleItems(p)
+		}
+		if d.noPropertyNames {
+			p.PropertyNames = nil
+		}
+		return p
+	})
+}
+
+// singleItems replaces the tuple of an array by one items schema accepting any of its
+// elements. A tuple without items after it limits the array to its length.
+func singleItems(p PropertyDefinition) PropertyDefinition {
+	elements := append([]PropertyDefinition{}, p.PrefixItems...)
+	if p.Items != nil {
+		elements = append(elements, *p.Items)
+	} else if p.MaxItems == nil {
+		length := len(p.PrefixItems)
+		p.MaxItems = &length
+	}
+
+	var distinct []PropertyDefinition
+	for _, element := range elements {
+		if !containsSchema(distinct, element) {
+			distinct = append(distinct, element)
+		}
+	}
+
+	items := distinct[0]
+	if len(distinct) > 1 {
+		items = PropertyDefinition{AnyOf: distinct, dialect: p.dialect}
+	}
+	p.Items = &items
+	p.PrefixItems = nil
+	return p
+}
+
+// containsSchema reports whether one of the schemas equals schema
+func containsSchema(schemas []PropertyDefinition, schema PropertyDefinition) bool {
+	for _, s := range schemas {
+		if reflect.DeepEqual(s, schema) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON writes the property with the keywords of the draft of the schema it
+// belongs to. On its own a property is written with the 2020-12 keywords.
+func (p PropertyDefinition) MarshalJSON() ([]byte, error) {
+	type property PropertyDefinition
+
+	d := p.dialect
+	tuple := d != nil && d.tupleItems && len(p.PrefixItems) > 0
+	exclusive := d != nil && d.booleanExclusive && (p.ExclusiveMinimum != nil || p.ExclusiveMaximum != nil)
+	nullable := d != nil && d.nullable && acceptsNull(p)
+	if !tuple && !exclusive && !nullable {
+		return json.Marshal(property(p))
+	}
+
+	// The fields below replace the ones of the same name in the embedded property
+	out := struct {
+		property
+		ExclusiveMinimum interface{}          `json:"exclusiveMinimum,omitempty"`
+		ExclusiveMaximum interface{}          `json:"exclusiveMaximum,omitempty"`
+		PrefixItems      []PropertyDefinition `json:"prefixItems,omitempty"`
+		Items            interface{}          `json:"items,omitempty"`
+		AdditionalItems  *PropertyDefinition  `json:"additionalItems,omitempty"`
+		AllOf            []PropertyDefinition `json:"allOf,omitempty"`
+		Nullable         bool                 `json:"nullable,omitempty"`
+	}{property: property(p)}
+
+	if nullable {
+		out.Nullable = true
+		out.Type = withoutNull(p.Type)
+		// A nullable reference is written as allOf the reference, since siblings of $ref are ignored
+		var anyOf []PropertyDefinition
+		for _, schema := range p.AnyOf {
+			if len(schema.Type) != 1 || schema.Type[0] != typeNull {
+				anyOf = append(anyOf, schema)
+			}
+		}
+		if len(anyOf) == 1 && anyOf[0].Ref != "" {
+			out.AllOf = anyOf
+			anyOf = nil
+		}
+		out.AnyOf = anyOf
+	}
+
+	if tuple {
+		out.Items = p.PrefixItems
+		out.AdditionalItems = p.Items
+	} else {
+		out.PrefixItems = p.PrefixItems
+		if p.Items != nil {
+			out.Items = p.Items
+		}
+	}
+
+	switch {
+	case !exclusive:
+		if p.ExclusiveMinimum != nil {
+			out.ExclusiveMinimum = *p.ExclusiveMinimum
+		}
+		if p.ExclusiveMaximum != nil {
+			out.ExclusiveMaximum = *p.ExclusiveMaximum
+		}
+	default:
+		// Only the stricter of an inclusive and an exclusive bound can be kept
+		if p.ExclusiveMinimum != nil && (p.Minimum == nil || *p.ExclusiveMinimum >= *p.Minimum) {
+			out.Minimum = p.ExclusiveMinimum
+			out.ExclusiveMinimum = true
+		}
+		if p.ExclusiveMaximum != nil && (p.Maximum == nil || *p.ExclusiveMaximum <= *p.Maximum) {
+			out.Maximum = p.ExclusiveMaximum
+			out.ExclusiveMaximum = true
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// acceptsNull reports whether a property allows null through its types or through
+// an anyOf branch of the null type
+func acceptsNull(p PropertyDefinition) bool {
+	if p.Type.Includes(typeNull) {
+		return true
+	}
+	for _, schema := range p.AnyOf {
+		if len(schema.Type) == 1 && schema.Type[0] == typeNull {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutNull returns the types other than null
+func withoutNull(types SchemaType) SchemaType {
+	var without SchemaType
+	for _, t := range types {
+		if t != typeNull {
+			without = append(without, t)
+		}
+	}
+	return without
+}
+
+// replaceRefPrefix respells the start of the fragment of a reference to a definition,
+// keeping the URI of the document it points to, e.g. "common.json#/$defs/Tags"
+func replaceRefPrefix(ref, prefix, replacement string) string {
+	uri, name, ok := strings.Cut(ref, prefix)
+	if !ok || strings.Contains(uri, "#") {
+		return ref
+	}
+	return uri + replacement + name
+}
+
+// UnmarshalJSON reads a schema written for any of the drafts, accepting "id" as
+// well as "$id" and "definitions" as well as "$defs". References to definitions
+// are rewritten to "#/$defs/..." like the ones of generated schemas.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type schema Schema
+
+	var in struct {
+		schema
+		LegacyID    string                        `json:"id"`
+		Definitions map[string]PropertyDefinition `json:"definitions"`
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*s = Schema(in.schema)
+	if s.ID == "" {
+		s.ID = in.LegacyID
+	}
+	if s.Definitions == nil && in.Definitions != nil {
+		s.Definitions = in.Definitions
+	}
+
+	legacy := dialects[Draft07].refPrefix
+	rewrite := func(p PropertyDefinition) PropertyDefinition {
+		p.Ref = replaceRefPrefix(p.Ref, legacy, defsRefPrefix)
+		return p
+	}
+	for name, property := range s.Properties {
+		s.Properties[name] = property.transform(rewrite)
+	}
+	if s.AdditionalProperties != nil {
+		additional := s.AdditionalProperties.transform(rewrite)
+		s.AdditionalProperties = &additional
+	}
+	for name, property := range s.Definitions {
+		s.Definitions[name] = property.transform(rewrite)
+	}
+
+	return nil
+}
+
+// UnmarshalJSON reads a property written for any of the drafts. An array of items
+// is read as prefixItems with additionalItems as items, and boolean exclusive bounds
+// turn minimum and maximum into exclusiveMinimum and exclusiveMaximum. The boolean
+// schemas true and false, e.g. `"additionalProperties": false`, are read as the
+// equivalent {} and {"not": {}}.
+func (p *PropertyDefinition) UnmarshalJSON(data []byte) error {
+	type property PropertyDefinition
+
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*p = PropertyDefinition{}
+		return nil
+	case "false":
+		*p = falseSchema()
+		return nil
+	}
+
+	// The fields below replace the ones of the same name in the embedded property
+	var in struct {
+		property
+		ExclusiveMinimum     json.RawMessage `json:"exclusiveMinimum"`
+		ExclusiveMaximum     json.RawMessage `json:"exclusiveMaximum"`
+		Items                json.RawMessage `json:"items"`
+		AdditionalItems      json.RawMessage `json:"additionalItems"`
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*p = PropertyDefinition(in.property)
+
+	var err error
+	if p.AdditionalProperties, err = subschema(in.AdditionalProperties); err != nil {
+		return fmt.Errorf("additionalProperties: %w", err)
+	}
+	if len(in.Items) > 0 && in.Items[0] == '[' {
+		if err := json.Unmarshal(in.Items, &p.PrefixItems); err != nil {
+			return err
+		}
+		if p.Items, err = subschema(in.AdditionalItems); err != nil {
+			return fmt.Errorf("additionalItems: %w", err)
+		}
+	} else if p.Items, err = subschema(in.Items); err != nil {
+		return fmt.Errorf("items: %w", err)
+	}
+
+	if p.ExclusiveMinimum, p.Minimum, err = exclusiveBound(in.ExclusiveMinimum, p.Minimum); err != nil {
+		return fmt.Errorf("exclusiveMinimum: %w", err)
+	}
+	if p.ExclusiveMaximum, p.Maximum, err = exclusiveBound(in.ExclusiveMaximum, p.Maximum); err != nil {
+		return fmt.Errorf("exclusiveMaximum: %w", err)
+	}
+
+	return nil
+}
+
+// subschema reads a keyword holding a schema, which may be a boolean schema
+func subschema(raw json.RawMessage) (*PropertyDefinition, error) {
+	switch string(raw) {
+	case "", "null":
+		return nil, nil
+	}
+
+	property := &PropertyDefinition{}
+	if err := json.Unmarshal(raw, property); err != nil {
+		return nil, err
+	}
+	return property, nil
+}
+
+// falseSchema returns the schema no value is valid against, written as the
+// boolean schema false in JSON Schema
+func falseSchema() PropertyDefinition {
+	return PropertyDefinition{Not: &PropertyDefinition{}}
+}
+
+// rejectsAll reports whether no value is valid against the property
+func rejectsAll(property PropertyDefinition) bool {
+	return property.Not != nil && reflect.DeepEqual(*property.Not, PropertyDefinition{})
+}
+
+// exclusiveBound reads an exclusiveMinimum or exclusiveMaximum, either a number or,
+// in draft-04, a boolean making the inclusive bound exclusive. It returns the
+// exclusive and the inclusive bound.
+func exclusiveBound(raw json.RawMessage, inclusive *float64) (*float64, *float64, error) {
+	switch string(raw) {
+	case "", "null", "false":
+		return nil, inclusive, nil
+	case "true":
+		return inclusive, nil, nil
+	}
+
+	var bound float64
+	if err := json.Unmarshal(raw, &bound); err != nil {
+		return nil, nil, fmt.Errorf("must be a number or a boolean: %w", err)
+	}
+	return &bound, inclusive, nil
+}
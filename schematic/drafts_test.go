@@ -0,0 +1,183 @@
+package schematic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type DraftLine struct {
+	SKU      string  `json:"sku"`
+	Quantity int     `json:"quantity" jsonschema:"exclusiveMinimum=0"`
+	Price    float64 `json:"price" jsonschema:"minimum=0,exclusiveMaximum=1000"`
+}
+
+type DraftOrder struct {
+	ID    string      `json:"id"`
+	Lines []DraftLine `json:"lines"`
+}
+
+func TestDraftOf(t *testing.T) {
+	require.Equal(t, Draft07, DraftOf("http://json-schema.org/draft-07/schema#"))
+	require.Equal(t, Draft07, DraftOf("https://json-schema.org/draft-07/schema"))
+	require.Equal(t, Draft201909, DraftOf("https://json-schema.org/draft/2019-09/schema#"))
+	require.Equal(t, Draft202012, DraftOf(""))
+	require.Equal(t, Draft202012, DraftOf("https://example.com/schema"))
+}
+
+func TestDraftKeywords(t *testing.T) {
+	tests := []struct {
+		draft    Draft
+		expected string
+	}{
+		{Draft202012, `{
+			"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "Order", "type": "object",
+			"required": ["id"],
+			"properties": {
+				"id": {"type": "string", "description": "ID"},
+				"lines": {"type": "array", "description": "Lines", "items": {"$ref": "#/$defs/DraftLine", "description": "Lines"}}
+			},
+			"$defs": {"DraftLine": {"type": "object", "description": "Lines", "required": ["sku", "quantity", "price"], "properties": {
+				"sku": {"type": "string", "description": "SKU"},
+				"quantity": {"type": "integer", "description": "Quantity", "exclusiveMinimum": 0},
+				"price": {"type": "number", "description": "Price", "minimum": 0, "exclusiveMaximum": 1000}
+			}}}
+		}`},
+		{Draft07, `{
+			"$schema": "http://json-schema.org/draft-07/schema#", "title": "Order", "type": "object",
+			"required": ["id"],
+			"properties": {
+				"id": {"type": "string", "description": "ID"},
+				"lines": {"type": "array", "description": "Lines", "items": {"$ref": "#/definitions/DraftLine", "description": "Lines"}}
+			},
+			"definitions": {"DraftLine": {"type": "object", "description": "Lines", "required": ["sku", "quantity", "price"], "properties": {
+				"sku": {"type": "string", "description": "SKU"},
+				"quantity": {"type": "integer", "description": "Quantity", "exclusiveMinimum": 0},
+				"price": {"type": "number", "description": "Price", "minimum": 0, "exclusiveMaximum": 1000}
+			}}}
+		}`},
+		{Draft04, `{
+			"$schema": "http://json-schema.org/draft-04/schema#", "title": "Order", "type": "object",
+			"required": ["id"],
+			"properties": {
+				"id": {"type": "string", "description": "ID"},
+				"lines": {"type": "array", "description": "Lines", "items": {"$ref": "#/definitions/DraftLine", "description": "Lines"}}
+			},
+			"definitions": {"DraftLine": {"type": "object", "description": "Lines", "required": ["sku", "quantity", "price"], "properties": {
+				"sku": {"type": "string", "description": "SKU"},
+				"quantity": {"type": "integer", "description": "Quantity", "minimum": 0, "exclusiveMinimum": true},
+				"price": {"type": "number", "description": "Price", "minimum": 0, "maximum": 1000, "exclusiveMaximum": true}
+			}}}
+		}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.draft), func(t *testing.T) {
+			schema := NewReflector(WithDraft(tt.draft), WithDefinitionPolicy(NamedDefinitions)).Reflect(DraftOrder{}, "Order")
+			require.Equal(t, tt.draft, schema.Draft())
+
+			marshaled, err := json.Marshal(schema)
+			require.NoError(t, err)
+			require.JSONEq(t, tt.expected, string(marshaled))
+
+			// Reading the schema back restores the generated keywords
+			var decoded Schema
+			require.NoError(t, json.Unmarshal(marshaled, &decoded))
+			require.Equal(t, schema, decoded)
+		})
+	}
+}
+
+func TestDraftTupleItems(t *testing.T) {
+	tuple := PropertyDefinition{
+		Type:        typeOf(typeArray),
+		PrefixItems: []PropertyDefinition{{Type: typeOf("string")}, {Type: typeOf("integer")}},
+		Items:       &PropertyDefinition{Type: typeOf("boolean")},
+	}
+	schema := Schema{Schema: string(Draft07), Type: "object", Properties: map[string]PropertyDefinition{"pair": tuple}}
+
+	marshaled, err := json.Marshal(schema)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "", "type": "object", "properties": {
+		"pair": {"type": "array", "items": [{"type": "string"}, {"type": "integer"}], "additionalItems": {"type": "boolean"}}
+	}}`, string(marshaled))
+
+	var decoded Schema
+	require.NoError(t, json.Unmarshal(marshaled, &decoded))
+	require.Equal(t, tuple, decoded.Properties["pair"])
+
+	schema.Schema = string(Draft202012)
+	marshaled, err = json.Marshal(schema)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "", "type": "object", "properties": {
+		"pair": {"type": "array", "prefixItems": [{"type": "string"}, {"type": "integer"}], "items": {"type": "boolean"}}
+	}}`, string(marshaled))
+
+	require.Empty(t, Validate(schema, []byte(`{"pair": ["a", 1, true, false]}`)))
+	require.Len(t, Validate(schema, []byte(`{"pair": [1, "a", "b"]}`)), 3)
+}
+
+func TestDraftMapKeys(t *testing.T) {
+	marshaled, err := json.Marshal(GenerateSchema(MapStruct{}, "Maps", string(Draft04)))
+	require.NoError(t, err)
+
+	// Draft-04 has no propertyNames, the integer keys can't be described
+	var written map[string]interface{}
+	require.NoError(t, json.Unmarshal(marshaled, &written))
+	byID := written["properties"].(map[string]interface{})["by_id"].(map[string]interface{})
+	require.NotContains(t, byID, "propertyNames")
+	require.Equal(t, "string", byID["additionalProperties"].(map[string]interface{})["type"])
+	require.NotContains(t, string(marshaled), "propertyNames")
+
+	marshaled, err = json.Marshal(GenerateSchema(MapStruct{}, "Maps", string(Draft06)))
+	require.NoError(t, err)
+	require.Contains(t, string(marshaled), "propertyNames")
+}
+
+func TestDraftBooleanSubschemas(t *testing.T) {
+	var schema Schema
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title": "Order",
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"address": {"type": "object", "additionalProperties": false, "properties": {"city": {"type": "string"}}},
+			"labels": {"type": "object", "additionalProperties": true},
+			"pair": {"type": "array", "items": [{"type": "string"}], "additionalItems": false},
+			"anything": {"type": "array", "items": true},
+			"counts": {"type": "object", "additionalProperties": {"type": "integer"}}
+		}
+	}`), &schema))
+
+	// Boolean subschemas are read as the equivalent schemas, other subschemas as usual
+	require.Equal(t, &PropertyDefinition{Not: &PropertyDefinition{}}, schema.AdditionalProperties)
+	require.Equal(t, &PropertyDefinition{Not: &PropertyDefinition{}}, schema.Properties["address"].AdditionalProperties)
+	require.Equal(t, typeOf("string"), schema.Properties["address"].Properties["city"].Type)
+	require.Equal(t, &PropertyDefinition{}, schema.Properties["labels"].AdditionalProperties)
+	require.Equal(t, []PropertyDefinition{{Type: typeOf("string")}}, schema.Properties["pair"].PrefixItems)
+	require.Equal(t, &PropertyDefinition{Not: &PropertyDefinition{}}, schema.Properties["pair"].Items)
+	require.Equal(t, &PropertyDefinition{}, schema.Properties["anything"].Items)
+	require.Equal(t, &PropertyDefinition{Type: typeOf("integer")}, schema.Properties["counts"].AdditionalProperties)
+
+	// The closed content models are enforced
+	require.Empty(t, Validate(schema, []byte(`{"address": {"city": "Berlin"}, "labels": {"a": 1}, "pair": ["a"], "anything": [1, "a"]}`)))
+	require.Equal(t, []string{
+		"/address/street: no value is allowed",
+		"/extra: no value is allowed",
+		"/pair/1: no value is allowed",
+	}, validationMessages(Validate(schema, []byte(`{"address": {"city": "Berlin", "street": "Main"}, "extra": 1, "pair": ["a", "b"]}`))))
+
+	// and written back in a form every draft and OpenAPI 3.0 understand
+	marshaled, err := json.Marshal(schema.Properties["address"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "object", "additionalProperties": {"not": {}}, "properties": {"city": {"type": "string"}}}`, string(marshaled))
+
+	var anyOf PropertyDefinition
+	require.NoError(t, json.Unmarshal([]byte(`{"anyOf": [false, {"type": "string"}]}`), &anyOf))
+	require.Equal(t, []PropertyDefinition{{Not: &PropertyDefinition{}}, {Type: typeOf("string")}}, anyOf.AnyOf)
+
+	var property PropertyDefinition
+	require.ErrorContains(t, json.Unmarshal([]byte(`{"additionalProperties": 1}`), &property), "additionalProperties")
+}
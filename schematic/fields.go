@@ -0,0 +1,207 @@
+package schematic
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// structField is a struct field as encoding/json sees it, after the fields of
+// embedded structs have been promoted into the outer struct
+type structField struct {
+	reflect.StructField
+
+	// name is the JSON property name of the field
+	name string
+	// tagged is true when the name comes from a json tag
+	tagged bool
+	// omitEmpty is true when the json tag carries the omitempty option
+	omitEmpty bool
+	// viaPointer is true when the field is promoted through an embedded pointer,
+	// in which case encoding/json omits it whenever that pointer is nil
+	viaPointer bool
+}
+
+// parseJSONTag splits a json struct tag into the name and its options
+func parseJSONTag(tag string) (string, []string) {
+	args := strings.Split(tag, ",")
+	return args[0], args[1:]
+}
+
+// hasOption reports whether the json tag options contain option
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// structFields returns the fields encoding/json encodes for the struct type t in
+// field order. Unexported fields are skipped and fields without a json name are
+// named by the naming strategy. Fields of embedded structs without a json name are
+// promoted using the rules of encoding/json: a shallower field hides deeper ones,
+// on the same depth a tagged field wins, and otherwise conflicting fields are all
+// dropped.
+func structFields(t reflect.Type, naming NamingStrategy) []structField {
+	type embedded struct {
+		typ        reflect.Type
+		index      []int
+		viaPointer bool
+	}
+
+	var fields []structField
+
+	current := []embedded{}
+	next := []embedded{{typ: t}}
+
+	// Types at the current and the next depth, used to detect a struct being
+	// embedded more than once at the same depth
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					// Embedded fields of unexported non-struct types are never encoded
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options := parseJSONTag(tag)
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				// A named or non-embedded field, or an embedded field that is not a struct
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					field := structField{
+						StructField: sf,
+						name:        name,
+						tagged:      name != "",
+						omitEmpty:   hasOption(options, "omitempty"),
+						viaPointer:  e.viaPointer,
+					}
+					field.Index = index
+					if field.name == "" {
+						field.name = naming(sf.Name)
+					}
+					fields = append(fields, field)
+
+					// A struct embedded twice at the same depth yields each of its
+					// fields twice so that they annihilate below
+					if count[e.typ] > 1 {
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				// Explore the embedded struct at the next depth
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, embedded{
+						typ:        ft,
+						index:      index,
+						viaPointer: e.viaPointer || sf.Type.Kind() == reflect.Ptr,
+					})
+				}
+			}
+		}
+	}
+
+	// Group fields by name, shallowest and tagged first, to find the dominant ones
+	sort.Slice(fields, func(i, j int) bool {
+		x, y := fields[i], fields[j]
+		if x.name != y.name {
+			return x.name < y.name
+		}
+		if len(x.Index) != len(y.Index) {
+			return len(x.Index) < len(y.Index)
+		}
+		if x.tagged != y.tagged {
+			return x.tagged
+		}
+		return indexLess(x.Index, y.Index)
+	})
+
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		name := fields[i].name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fields[i])
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+	fields = out
+
+	// Restore the declaration order
+	sort.Slice(fields, func(i, j int) bool {
+		return indexLess(fields[i].Index, fields[j].Index)
+	})
+
+	return fields
+}
+
+// dominantField picks the field that wins among fields sharing a JSON name. The
+// fields are sorted by depth and tagging, so the first one wins unless the
+// second one is on the same depth and equally tagged, which is a conflict.
+func dominantField(fields []structField) (structField, bool) {
+	if len(fields) > 1 &&
+		len(fields[0].Index) == len(fields[1].Index) &&
+		fields[0].tagged == fields[1].tagged {
+		return structField{}, false
+	}
+	return fields[0], true
+}
+
+// indexLess orders two field index paths
+func indexLess(x, y []int) bool {
+	for k, xik := range x {
+		if k >= len(y) {
+			return false
+		}
+		if xik != y[k] {
+			return xik < y[k]
+		}
+	}
+	return len(x) < len(y)
+}
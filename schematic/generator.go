@@ -2,368 +2,512 @@ package schematic
 
 import (
 	"reflect"
-	"strconv"
 	"strings"
-	"unicode"
 )
 
 const typeArray string = "array"
 
-// toSnakeCase converts PascalCase or camelCase to snake_case
-func toSnakeCase(s string) string {
-	var result strings.Builder
+// Schema represents a JSON Schema definition
+type Schema struct {
+	Schema               string                        `json:"$schema"`
+	ID                   string                        `json:"$id,omitempty"`
+	Title                string                        `json:"title"`
+	Type                 string                        `json:"type"`
+	Required             []string                      `json:"required,omitempty"`
+	Properties           map[string]PropertyDefinition `json:"properties"`
+	AdditionalProperties *PropertyDefinition           `json:"additionalProperties,omitempty"`
+	Definitions          map[string]PropertyDefinition `json:"$defs,omitempty"`
+
+	// definitionsOnly is set on the common schema of BuildEvents, which describes no
+	// payload and is written without type and properties
+	definitionsOnly bool
+
+	// idBase is the IDBaseURI of the Reflector, BuildEvents adds the file name to
+	// make the $id
+	idBase string
+}
 
-	for i, r := range s {
-		if unicode.IsUpper(r) {
-			if i > 0 {
-				result.WriteByte('_')
-			}
-			result.WriteRune(unicode.ToLower(r))
-		} else {
-			result.WriteRune(r)
-		}
+// rootProperty returns the schema of the payloads described by a Schema
+func rootProperty(schema Schema) PropertyDefinition {
+	return PropertyDefinition{
+		Type:                 typeOf(schema.Type),
+		Required:             schema.Required,
+		Properties:           schema.Properties,
+		AdditionalProperties: schema.AdditionalProperties,
 	}
+}
 
-	return result.String()
+// PropertyDefinition represents a property within a JSON Schema
+type PropertyDefinition struct {
+	Type                 SchemaType                    `json:"type,omitempty"`
+	Description          string                        `json:"description,omitempty"`
+	Format               string                        `json:"format,omitempty"`
+	Enum                 []interface{}                 `json:"enum,omitempty"`
+	Minimum              *float64                      `json:"minimum,omitempty"`
+	Maximum              *float64                      `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64                      `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64                      `json:"exclusiveMaximum,omitempty"`
+	MultipleOf           *float64                      `json:"multipleOf,omitempty"`
+	MinLength            *int                          `json:"minLength,omitempty"`
+	MaxLength            *int                          `json:"maxLength,omitempty"`
+	Pattern              string                        `json:"pattern,omitempty"`
+	MinItems             *int                          `json:"minItems,omitempty"`
+	MaxItems             *int                          `json:"maxItems,omitempty"`
+	UniqueItems          bool                          `json:"uniqueItems,omitempty"`
+	Required             []string                      `json:"required,omitempty"`
+	PrefixItems          []PropertyDefinition          `json:"prefixItems,omitempty"`
+	Items                *PropertyDefinition           `json:"items,omitempty"`
+	Properties           map[string]PropertyDefinition `json:"properties,omitempty"`
+	AdditionalProperties *PropertyDefinition           `json:"additionalProperties,omitempty"`
+	PropertyNames        *PropertyDefinition           `json:"propertyNames,omitempty"`
+	AnyOf                []PropertyDefinition          `json:"anyOf,omitempty"`
+	Not                  *PropertyDefinition           `json:"not,omitempty"`
+	Ref                  string                        `json:"$ref,omitempty"`
+
+	// dialect is the draft the property is marshaled for, set on the copy made by Schema.MarshalJSON
+	dialect *dialect
 }
 
-// Schema represents a JSON Schema definition
-type Schema struct {
-	Schema      string                        `json:"$schema"`
-	Title       string                        `json:"title"`
-	Type        string                        `json:"type"`
-	Required    []string                      `json:"required,omitempty"`
-	Properties  map[string]PropertyDefinition `json:"properties"`
-	Definitions map[string]PropertyDefinition `json:"$defs,omitempty"`
+// clone returns a deep copy of the property so that it can be changed without
+// affecting the original, e.g. a registered type mapping
+func (p PropertyDefinition) clone() PropertyDefinition {
+	if p.Type != nil {
+		p.Type = append(SchemaType{}, p.Type...)
+	}
+	if p.Enum != nil {
+		p.Enum = append([]interface{}{}, p.Enum...)
+	}
+	if p.Required != nil {
+		p.Required = append([]string{}, p.Required...)
+	}
+	if p.PrefixItems != nil {
+		prefixItems := make([]PropertyDefinition, len(p.PrefixItems))
+		for i, schema := range p.PrefixItems {
+			prefixItems[i] = schema.clone()
+		}
+		p.PrefixItems = prefixItems
+	}
+	if p.Items != nil {
+		items := p.Items.clone()
+		p.Items = &items
+	}
+	if p.Properties != nil {
+		properties := make(map[string]PropertyDefinition, len(p.Properties))
+		for name, property := range p.Properties {
+			properties[name] = property.clone()
+		}
+		p.Properties = properties
+	}
+	if p.AdditionalProperties != nil {
+		additional := p.AdditionalProperties.clone()
+		p.AdditionalProperties = &additional
+	}
+	if p.PropertyNames != nil {
+		names := p.PropertyNames.clone()
+		p.PropertyNames = &names
+	}
+	if p.AnyOf != nil {
+		anyOf := make([]PropertyDefinition, len(p.AnyOf))
+		for i, schema := range p.AnyOf {
+			anyOf[i] = schema.clone()
+		}
+		p.AnyOf = anyOf
+	}
+	if p.Not != nil {
+		not := p.Not.clone()
+		p.Not = &not
+	}
+	return p
 }
 
-// PropertyDefinition represents a property within a JSON Schema
-type PropertyDefinition struct {
-	Type        string                        `json:"type,omitempty"`
-	Description string                        `json:"description,omitempty"`
-	Format      string                        `json:"format,omitempty"`
-	Required    []string                      `json:"required,omitempty"`
-	Items       *PropertyDefinition           `json:"items,omitempty"`
-	Properties  map[string]PropertyDefinition `json:"properties,omitempty"`
-	Ref         string                        `json:"$ref,omitempty"`
+// transform returns a copy of the property with fn applied to every schema it
+// contains, innermost first, and finally to the property itself
+func (p PropertyDefinition) transform(fn func(PropertyDefinition) PropertyDefinition) PropertyDefinition {
+	if p.PrefixItems != nil {
+		prefixItems := make([]PropertyDefinition, len(p.PrefixItems))
+		for i, schema := range p.PrefixItems {
+			prefixItems[i] = schema.transform(fn)
+		}
+		p.PrefixItems = prefixItems
+	}
+	if p.Items != nil {
+		items := p.Items.transform(fn)
+		p.Items = &items
+	}
+	if p.Properties != nil {
+		properties := make(map[string]PropertyDefinition, len(p.Properties))
+		for name, property := range p.Properties {
+			properties[name] = property.transform(fn)
+		}
+		p.Properties = properties
+	}
+	if p.AdditionalProperties != nil {
+		additional := p.AdditionalProperties.transform(fn)
+		p.AdditionalProperties = &additional
+	}
+	if p.PropertyNames != nil {
+		names := p.PropertyNames.transform(fn)
+		p.PropertyNames = &names
+	}
+	if p.AnyOf != nil {
+		anyOf := make([]PropertyDefinition, len(p.AnyOf))
+		for i, schema := range p.AnyOf {
+			anyOf[i] = schema.transform(fn)
+		}
+		p.AnyOf = anyOf
+	}
+	if p.Not != nil {
+		not := p.Not.transform(fn)
+		p.Not = &not
+	}
+	return fn(p)
 }
 
 // fieldInfo contains information about a struct field for schema generation
 type fieldInfo struct {
 	Field       reflect.StructField
 	TagName     string
-	TypeName    string
-	Format      string
-	SliceFormat string
-	SliceType   string
-	SkipNested  bool
-	IsArray     bool
+	Constraints constraints
 }
 
 // schemaContext tracks state during schema generation
 type schemaContext struct {
-	visited     map[reflect.Type]bool
-	definitions map[string]PropertyDefinition
-	counter     int
+	reflector *Reflector
+	warned    map[reflect.Type]bool
+
+	// definitions holds the types placed in $defs and order the same definitions in the
+	// order they were first referenced
+	definitions map[reflect.Type]*definition
+	order       []*definition
+
+	// building holds the structs currently being built, innermost last
+	building []buildFrame
+	// recursive holds the structs that contain themselves, directly or through other types
+	recursive map[reflect.Type]bool
+	// roots holds the structs whose definitions are kept whatever the DefinitionPolicy
+	roots map[reflect.Type]bool
 }
 
 // GenerateProperties creates JSON Schema properties from a Go struct type
-func GenerateProperties[T any](object T) map[string]PropertyDefinition {
-	ctx := &schemaContext{
-		visited:     make(map[reflect.Type]bool),
-		definitions: make(map[string]PropertyDefinition),
-	}
-	properties, _ := ctx.buildProperties(reflect.TypeOf(object), 0)
-	return properties
+func GenerateProperties[T any](object T, opts ...Option) map[string]PropertyDefinition {
+	return NewReflector(opts...).Properties(object)
 }
 
 // GenerateSchema creates a complete JSON Schema with definitions from a Go struct type
-func GenerateSchema[T any](object T, title, schemaURL string) Schema {
-	ctx := &schemaContext{
-		visited:     make(map[reflect.Type]bool),
-		definitions: make(map[string]PropertyDefinition),
-	}
-	properties, _ := ctx.buildProperties(reflect.TypeOf(object), 0)
+func GenerateSchema[T any](object T, title, schemaURL string, opts ...Option) Schema {
+	return NewReflector(append([]Option{WithSchemaURL(schemaURL)}, opts...)...).Reflect(object, title)
+}
 
-	schema := Schema{
-		Schema:     schemaURL,
-		Title:      title,
-		Type:       "object",
-		Required:   GenerateRequired(object, nil),
-		Properties: properties,
+// buildProperties creates the properties and the required fields of a struct, of the
+// struct a pointer points to or of the struct elements of a slice or array. The name
+// is the field the struct was reached through.
+func (ctx *schemaContext) buildProperties(t reflect.Type, name string) (map[string]PropertyDefinition, []string) {
+	structType, ok := structOf(t)
+	if !ok {
+		return map[string]PropertyDefinition{}, requiredFields(t, ctx.reflector.naming())
 	}
 
-	if len(ctx.definitions) > 0 {
-		schema.Definitions = ctx.definitions
-	}
+	// Track the structs being built so that recursive types can be detected
+	ctx.building = append(ctx.building, buildFrame{t: structType, field: name})
+	properties := ctx.reflectStruct(structType)
+	ctx.building = ctx.building[:len(ctx.building)-1]
 
-	return schema
+	return properties, requiredFields(structType, ctx.reflector.naming())
 }
 
-func (ctx *schemaContext) buildProperties(t reflect.Type, nestedCounter int) (map[string]PropertyDefinition, []string) {
-	properties := map[string]PropertyDefinition{}
-	var required []string
-
-	switch t.Kind() {
-	case reflect.Slice:
-		t := t.Elem()
+// structOf returns the struct type described by t, dereferencing pointers and the
+// elements of slices and arrays
+func structOf(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
 		if t.Kind() == reflect.Ptr {
 			t = t.Elem()
 		}
-		if t.Kind() != reflect.Struct {
-			break
-		}
-		if ctx.visited[t] && nestedCounter > 1 {
-			// Don't blow up on recursive type definition.
-			break
-		}
-		ctx.visited[t] = true
-		properties = ctx.reflectStruct(t, nestedCounter)
-	case reflect.Struct:
-		if ctx.visited[t] && nestedCounter > 1 {
-			// Don't blow up on recursive type definition.
-			break
-		}
-		ctx.visited[t] = true
-		properties = ctx.reflectStruct(t, nestedCounter)
-	case reflect.Ptr:
-		if t.Elem().Kind() == reflect.Slice &&
-			t.Elem().Elem().Kind() == reflect.Struct {
-			t = t.Elem()
-		}
-		if t.Elem().Kind() != reflect.Struct {
-			break
-		}
-		// get the underlying pointer element and reflect it as struct
-		t := t.Elem()
-		if ctx.visited[t] && nestedCounter > 1 {
-			// Don't blow up on recursive type definition.
-			break
-		}
-		ctx.visited[t] = true
-		properties = ctx.reflectStruct(t, nestedCounter)
 	}
-
-	// build required field for nested struct
-	required = GenerateRequired(nil, t)
-
-	return properties, required
+	return t, t.Kind() == reflect.Struct
 }
 
-// reflectStruct processes a struct type and generates properties for all its fields
-func (ctx *schemaContext) reflectStruct(t reflect.Type, nestedCounter int) map[string]PropertyDefinition {
+// reflectStruct processes a struct type and generates properties for all its fields,
+// including the fields promoted from embedded structs
+func (ctx *schemaContext) reflectStruct(t reflect.Type) map[string]PropertyDefinition {
 	properties := map[string]PropertyDefinition{}
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
+	for _, field := range structFields(t, ctx.reflector.naming()) {
 		fieldInfo := ctx.extractFieldInfo(field)
-
-		if fieldInfo.TagName == "-" || fieldInfo.TagName == "" {
-			continue // Skip fields with json:"-" or invalid tag names
-		}
-
-		property := ctx.buildFieldProperty(fieldInfo, nestedCounter)
+		property := ctx.buildFieldProperty(fieldInfo)
 		properties[fieldInfo.TagName] = property
 	}
 
 	return properties
 }
 
-// extractFieldInfo extracts field information needed for schema generation
-func (ctx *schemaContext) extractFieldInfo(field reflect.StructField) fieldInfo {
-	tag := field.Tag.Get("json")
-	args := strings.Split(tag, ",")
-	tagName := args[0]
-
-	// Skip fields with json:"-"
-	if tagName == "-" {
-		return fieldInfo{Field: field, TagName: "-"}
-	}
-
-	// If field has no json tag, use the variable name converted to snake_case
-	if len(tagName) == 0 {
-		tagName = toSnakeCase(field.Name)
+// extractFieldInfo extracts field information needed for schema generation. The
+// unknown keywords and invalid values of its `jsonschema` tag are reported through
+// the Reflector's Warnf and ignored.
+func (ctx *schemaContext) extractFieldInfo(field structField) fieldInfo {
+	constraints, errs := parseConstraints(field.Tag.Get("jsonschema"))
+	if ctx.reflector.Warnf != nil {
+		for _, err := range errs {
+			ctx.reflector.Warnf("schematic: ignoring jsonschema tag on field %s: %s", field.Name, err)
+		}
 	}
 
-	info := fieldInfo{
-		Field:   field,
-		TagName: tagName,
+	return fieldInfo{
+		Field:       field.StructField,
+		TagName:     field.name,
+		Constraints: constraints,
 	}
+}
 
-	// Determine the type information based on field type
-	ctx.analyzeFieldType(&info)
+// buildFieldProperty creates a PropertyDefinition for a single field including
+// the validation keywords declared in its `jsonschema` tag
+func (ctx *schemaContext) buildFieldProperty(info fieldInfo) PropertyDefinition {
+	property := ctx.buildType(info.Field.Type, info.Field.Name)
+	info.Constraints.apply(&property)
+	ctx.applyNullable(info.Field.Type, &property)
+	return property
+}
 
-	return info
+// buildTypeProperty creates a PropertyDefinition for a type that is not attached to
+// a struct field, such as the values of a map
+func (ctx *schemaContext) buildTypeProperty(t reflect.Type, name string) PropertyDefinition {
+	property := ctx.buildType(t, name)
+	ctx.applyNullable(t, &property)
+	return property
 }
 
-// analyzeFieldType determines the JSON Schema type information for a field
-func (ctx *schemaContext) analyzeFieldType(info *fieldInfo) {
-	fieldType := info.Field.Type
-	info.TypeName = fieldType.String()
+// applyNullable allows null for pointer types when nullable pointers are enabled, which is
+// what encoding/json writes for a nil pointer. References to definitions are marked so that
+// they accept null once it is known whether the definition is inlined.
+func (ctx *schemaContext) applyNullable(t reflect.Type, property *PropertyDefinition) {
+	if !ctx.reflector.NullablePointers || t.Kind() != reflect.Ptr {
+		return
+	}
 
-	// Handle interface{} specially
-	if info.TypeName == "interface{}" {
-		info.TypeName = ""
-		info.Format = ""
-		info.SkipNested = true
+	if strings.HasPrefix(property.Ref, definitionRefPrefix) {
+		property.Ref += nullableRefSuffix
 		return
 	}
 
-	// Handle []byte specially - should be string with byte format, not array
-	if info.TypeName == "[]byte" || info.TypeName == "[]uint8" {
-		info.TypeName = "string"
-		info.Format = "byte"
-		info.SkipNested = true
-		info.IsArray = false
+	makeNullable(property)
+}
+
+// makeNullable adds null to the types of a property. References can't carry a type so
+// they are wrapped in anyOf together with the null type.
+func makeNullable(property *PropertyDefinition) {
+	if property.Ref != "" {
+		property.AnyOf = []PropertyDefinition{{Ref: property.Ref}, {Type: typeOf(typeNull)}}
+		property.Ref = ""
 		return
 	}
 
-	switch fieldType.Kind() {
-	case reflect.Slice:
-		info.IsArray = true
-		info.TypeName = typeArray
-		ctx.handleSliceType(info, fieldType)
-	case reflect.Ptr:
-		ctx.handlePointerType(info, fieldType)
-	default:
-		info.TypeName, info.Format, info.SkipNested = convertToEventName(info.TypeName, &fieldType)
+	if len(property.Type) == 0 || property.Type.Includes(typeNull) {
+		return
+	}
+	property.Type = append(append(SchemaType{}, property.Type...), typeNull)
+	if len(property.Enum) > 0 {
+		property.Enum = append(property.Enum, nil)
 	}
 }
 
-// handleSliceType processes slice/array field types
-func (ctx *schemaContext) handleSliceType(info *fieldInfo, fieldType reflect.Type) {
-	elemType := fieldType.Elem()
-	info.SliceType = elemType.Kind().String()
+// buildType creates the PropertyDefinition describing a Go type, recursing into the
+// elements of slices, arrays and maps and into the fields of structs, so collections
+// can be nested to any depth. Structs are described by a reference to their definition,
+// the DefinitionPolicy decides which of them are inlined in the end.
+func (ctx *schemaContext) buildType(t reflect.Type, name string) PropertyDefinition {
+	// Registered type mappings and self-describing types take precedence over everything else
+	if custom, ok := ctx.customProperty(t); ok {
+		return withDescription(custom, name)
+	}
 
-	if info.SliceType == "ptr" {
-		info.SliceType = elemType.String()
+	// Pointers are described by the type they point to
+	elemType := t
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
 	}
 
-	info.SliceType, info.SliceFormat, info.SkipNested = convertToEventName(info.SliceType, nil)
-}
+	// Well known types such as time.Time
+	if mapping, exists := typeMapping[elemType.String()]; exists {
+		return PropertyDefinition{
+			Type:        typeOf(mapping.jsonType),
+			Description: name,
+			Format:      mapping.format,
+		}
+	}
 
-// handlePointerType processes pointer field types
-func (ctx *schemaContext) handlePointerType(info *fieldInfo, fieldType reflect.Type) {
-	elemType := fieldType.Elem()
+	switch elemType.Kind() {
+	case reflect.Slice:
+		// []byte is encoded as a base64 string, not as an array
+		if elemType.Elem().Kind() == reflect.Uint8 {
+			return PropertyDefinition{
+				Type:        typeOf("string"),
+				Description: name,
+				Format:      "byte",
+			}
+		}
+		return ctx.buildArrayProperty(elemType, name)
+	case reflect.Array:
+		return ctx.buildArrayProperty(elemType, name)
+	case reflect.Map:
+		return ctx.buildMapProperty(elemType, name)
+	case reflect.Struct:
+		if ref, ok := ctx.existingReference(elemType, name); ok {
+			return ref
+		}
 
-	if elemType.Kind() == reflect.Slice {
-		info.IsArray = true
-		info.TypeName = typeArray
-		info.SliceType = elemType.Elem().String()
-		info.SliceType, info.SliceFormat, info.SkipNested = convertToEventName(info.SliceType, &fieldType)
-	} else {
-		info.TypeName, info.Format, info.SkipNested = convertToEventName(info.TypeName, &fieldType)
+		nested, required := ctx.buildProperties(elemType, name)
+		return ctx.createDefinitionReference(elemType, name, nested, required)
 	}
-}
 
-// buildFieldProperty creates a PropertyDefinition for a single field
-func (ctx *schemaContext) buildFieldProperty(info fieldInfo, nestedCounter int) PropertyDefinition {
-	var nested map[string]PropertyDefinition
-	var required []string
+	typeName, format := convertToEventName(elemType.String(), &elemType)
 
-	// Handle nested structures
-	if !info.SkipNested {
-		nestedCounter++
-		nested, required = ctx.buildProperties(info.Field.Type, nestedCounter)
-		nestedCounter = 0 // Reset counter for next field
+	return PropertyDefinition{
+		Type:        typeOf(typeName),
+		Description: name,
+		Format:      format,
 	}
+}
 
-	// Check if this is a reusable type that should be in definitions
-	if ctx.shouldUseDefinition(info.Field.Type, nested) {
-		return ctx.createDefinitionReference(info, nested, required)
+// existingReference returns a $ref for a struct whose definition is already stored, or
+// for a struct that is already being built further up, which makes it and every struct
+// built in between recursive
+func (ctx *schemaContext) existingReference(t reflect.Type, name string) (PropertyDefinition, bool) {
+	for i, frame := range ctx.building {
+		if frame.t != t {
+			continue
+		}
+		// Anonymous structs can't refer back to themselves, so they are left to the DefinitionPolicy
+		for _, cycle := range ctx.building[i:] {
+			if cycle.t.Name() != "" {
+				ctx.recursive[cycle.t] = true
+			}
+		}
+		return definitionReference(ctx.definitionFor(t, name).ref, name), true
 	}
 
-	if info.IsArray {
-		return ctx.buildArrayProperty(info, nested, required)
+	if def, ok := ctx.definitions[t]; ok && def.stored {
+		return definitionReference(def.ref, name), true
 	}
 
-	return ctx.buildObjectProperty(info, nested, required)
+	return PropertyDefinition{}, false
 }
 
-// shouldUseDefinition determines if a type should be moved to $defs for reuse
-func (ctx *schemaContext) shouldUseDefinition(fieldType reflect.Type, nested map[string]PropertyDefinition) bool {
-	// Only create definitions for complex structs with multiple properties
-	return len(nested) > 2 && fieldType.Kind() == reflect.Struct
-}
-
-// createDefinitionReference creates a $ref to a definition and stores the definition
-func (ctx *schemaContext) createDefinitionReference(info fieldInfo, nested map[string]PropertyDefinition, required []string) PropertyDefinition {
-	defName := info.Field.Type.Name()
-	if defName == "" {
-		defName = "AnonymousStruct" + strconv.Itoa(ctx.counter)
-		ctx.counter++
-	}
+// createDefinitionReference creates a $ref to the definition of a struct and stores the definition
+func (ctx *schemaContext) createDefinitionReference(t reflect.Type, name string, nested map[string]PropertyDefinition, required []string) PropertyDefinition {
+	def := ctx.definitionFor(t, name)
 
 	// Store in definitions if not already present
-	if _, exists := ctx.definitions[defName]; !exists {
-		typeName := "object"
-		if len(nested) > 0 {
-			typeName = "object"
-		}
-
-		ctx.definitions[defName] = PropertyDefinition{
-			Type:        typeName,
+	if !def.stored {
+		def.property = PropertyDefinition{
+			Type:        typeOf("object"),
 			Properties:  nested,
 			Required:    required,
-			Description: info.Field.Name,
+			Description: name,
 		}
+		def.stored = true
 	}
 
+	return definitionReference(def.ref, name)
+}
+
+// definitionReference creates the $ref to a definition. The reference is a
+// placeholder until the names of all definitions are resolved.
+func definitionReference(ref, name string) PropertyDefinition {
 	return PropertyDefinition{
-		Ref:         "#/$defs/" + defName,
-		Description: info.Field.Name,
+		Ref:         ref,
+		Description: name,
 	}
 }
 
-// buildArrayProperty creates a PropertyDefinition for array/slice fields
-func (ctx *schemaContext) buildArrayProperty(info fieldInfo, nested map[string]PropertyDefinition, required []string) PropertyDefinition {
-	sliceTypeName := info.SliceType
-	if len(nested) > 0 {
-		sliceTypeName = "object"
+// buildArrayProperty creates a PropertyDefinition for slices and fixed-size arrays,
+// describing the elements by recursing into the element type
+func (ctx *schemaContext) buildArrayProperty(arrayType reflect.Type, name string) PropertyDefinition {
+	items := ctx.buildType(arrayType.Elem(), name)
+	ctx.applyNullable(arrayType.Elem(), &items)
+
+	property := PropertyDefinition{
+		Type:        typeOf(typeArray),
+		Description: name,
+		Items:       &items,
 	}
 
-	items := &PropertyDefinition{
-		Type:        sliceTypeName,
-		Description: info.Field.Name,
-		Properties:  nested,
-		Format:      info.SliceFormat,
-		Required:    required,
+	// Fixed-size arrays are always encoded with all of their elements
+	if arrayType.Kind() == reflect.Array {
+		length := arrayType.Len()
+		property.MinItems = &length
+		property.MaxItems = &length
 	}
 
-	return PropertyDefinition{
-		Type:        typeArray,
-		Description: info.Field.Name,
-		Format:      info.Format,
-		Items:       items,
-		Required:    required,
+	return property
+}
+
+// buildMapProperty creates a PropertyDefinition for maps. The value type is
+// described by additionalProperties and non-string keys by propertyNames.
+func (ctx *schemaContext) buildMapProperty(mapType reflect.Type, name string) PropertyDefinition {
+	property := PropertyDefinition{
+		Type:          typeOf("object"),
+		Description:   name,
+		PropertyNames: ctx.buildMapKeyProperty(mapType.Key()),
 	}
+
+	// interface{} values accept anything which is the default for additionalProperties
+	if mapType.Elem().Kind() != reflect.Interface {
+		values := ctx.buildTypeProperty(mapType.Elem(), name)
+		property.AdditionalProperties = &values
+	}
+
+	return property
 }
 
-// buildObjectProperty creates a PropertyDefinition for object/struct fields
-func (ctx *schemaContext) buildObjectProperty(info fieldInfo, nested map[string]PropertyDefinition, required []string) PropertyDefinition {
-	typeName := info.TypeName
-	if len(nested) > 0 && typeName != typeArray {
-		typeName = "object"
+// buildMapKeyProperty describes the keys of a map the way encoding/json encodes them.
+// Plain string and text keys need no constraint, integer keys are written as decimal strings.
+func (ctx *schemaContext) buildMapKeyProperty(keyType reflect.Type) *PropertyDefinition {
+	// Keys that aren't strings but implement encoding.TextMarshaler are written as their
+	// text. Map keys aren't addressable, so methods with pointer receivers don't count.
+	if keyType.Kind() != reflect.String && keyType.Implements(textMarshalerType) {
+		return nil
 	}
 
-	return PropertyDefinition{
-		Type:        typeName,
-		Description: info.Field.Name,
-		Properties:  nested,
-		Format:      info.Format,
-		Required:    required,
+	switch keyType.Kind() {
+	case reflect.String:
+		if keyType.PkgPath() == "" {
+			return nil
+		}
+		keys := ctx.buildTypeProperty(keyType, keyType.Name())
+		return &keys
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &PropertyDefinition{Type: typeOf("string"), Pattern: "^-?[0-9]+$"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &PropertyDefinition{Type: typeOf("string"), Pattern: "^[0-9]+$"}
+	}
+	return nil
+}
+
+// withDescription sets the description of a property unless it already has one
+func withDescription(property PropertyDefinition, description string) PropertyDefinition {
+	if property.Description == "" {
+		property.Description = description
 	}
+	return property
 }
 
 // GenerateRequired determines which fields are required in a JSON Schema based on Go struct tags
 // Fields are considered required if they don't have the "omitempty" tag and are not pointer types
-func GenerateRequired(object interface{}, nestedObject reflect.Type) []string {
+func GenerateRequired(object interface{}, nestedObject reflect.Type, opts ...Option) []string {
 	t := reflect.TypeOf(object)
 
 	if nestedObject != nil {
 		t = nestedObject
 	}
 
+	return NewReflector(opts...).Required(t)
+}
+
+// requiredFields lists the JSON names of the required fields of a struct type
+func requiredFields(t reflect.Type, naming NamingStrategy) []string {
 	var require []string
 
 	// Handle nil type
@@ -391,12 +535,9 @@ func GenerateRequired(object interface{}, nestedObject reflect.Type) []string {
 		return require
 	}
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		tag := field.Tag.Get("json")
-
-		// Skip fields with json:"-"
-		if tag == "-" {
+	for _, field := range structFields(t, naming) {
+		// fields promoted through an embedded pointer are absent when it is nil
+		if field.viaPointer {
 			continue
 		}
 
@@ -405,119 +546,73 @@ func GenerateRequired(object interface{}, nestedObject reflect.Type) []string {
 		}
 
 		if field.Type.Kind() != reflect.Ptr {
-			args := strings.Split(tag, ",")
-			tagName := args[0]
-			// if field has no json tag, use the variable name converted to snake_case
-			if len(tagName) == 0 {
-				tagName = toSnakeCase(field.Name)
-			}
-			omitempty := strings.Join(args[1:], ",")
-
-			if !strings.Contains(omitempty, "omitempty") {
-				require = append(require, tagName)
+			if !field.omitEmpty {
+				require = append(require, field.name)
 			}
-		} else {
+		} else if field.name == "tags" {
 			// handle special case for tags field
-			args := strings.Split(tag, ",")
-			tagName := args[0]
-			if tagName == "tags" {
-				require = append(require, tagName)
-			}
+			require = append(require, field.name)
 		}
 	}
 
 	return require
 }
 
+// typeMapping describes well known and scalar types by their printed name.
+// Pointers are dereferenced before the lookup.
 var typeMapping = map[string]struct {
-	jsonType   string
-	format     string
-	skipNested bool
+	jsonType string
+	format   string
 }{
-	"uuid.UUID":        {"string", "uuid", true},
-	"EventName":        {"string", "", true},
-	"*string":          {"string", "", true},
-	"string":           {"string", "", true},
-	"float64":          {"number", "", true},
-	"*float64":         {"number", "", true},
-	"float32":          {"number", "", true},
-	"*float32":         {"number", "", true},
-	"int":              {"integer", "", true},
-	"*int":             {"integer", "", true},
-	"uint":             {"integer", "", true},
-	"*uint":            {"integer", "", true},
-	"uint8":            {"integer", "", true},
-	"*uint8":           {"integer", "", true},
-	"uint16":           {"integer", "", true},
-	"*uint16":          {"integer", "", true},
-	"uint32":           {"integer", "", true},
-	"*uint32":          {"integer", "", true},
-	"uint64":           {"integer", "", true},
-	"*uint64":          {"integer", "", true},
-	"int8":             {"integer", "", true},
-	"*int8":            {"integer", "", true},
-	"int16":            {"integer", "", true},
-	"*int16":           {"integer", "", true},
-	"int32":            {"integer", "", true},
-	"*int32":           {"integer", "", true},
-	"int64":            {"integer", "", true},
-	"*int64":           {"integer", "", true},
-	"bool":             {"boolean", "", true},
-	"*bool":            {"boolean", "", true},
-	"time.Time":        {"string", "date-time", true},
-	"*time.Time":       {"string", "date-time", true},
-	"interface{}":      {}, // Will be handled as any type
-	"*interface{}":     {}, // Will be handled as any type
-	"any":              {}, // Will be handled as any type
-	"json.RawMessage":  {"string", "", true},
-	"*json.RawMessage": {"string", "", true},
-	"[]byte":           {"string", "byte", true},
-	"*[]byte":          {"string", "byte", true},
+	"uuid.UUID":       {"string", "uuid"},
+	"EventName":       {"string", ""},
+	"string":          {"string", ""},
+	"float64":         {"number", ""},
+	"float32":         {"number", ""},
+	"int":             {"integer", ""},
+	"uint":            {"integer", ""},
+	"uint8":           {"integer", ""},
+	"uint16":          {"integer", ""},
+	"uint32":          {"integer", ""},
+	"uint64":          {"integer", ""},
+	"int8":            {"integer", ""},
+	"int16":           {"integer", ""},
+	"int32":           {"integer", ""},
+	"int64":           {"integer", ""},
+	"bool":            {"boolean", ""},
+	"time.Time":       {"string", "date-time"},
+	"json.RawMessage": {"string", ""},
 }
 
-func convertToEventName(defType string, refType *reflect.Type) (string, string, bool) {
-	// Handle interface{} and any type - should accept any value
-	if defType == "interface{}" || defType == "*interface{}" {
-		return "", "", true // No type constraint - accepts anything
-	}
-
+// convertToEventName returns the JSON type and format of a type that is neither a
+// struct nor a collection
+func convertToEventName(defType string, refType *reflect.Type) (string, string) {
 	// Check if type exists in our mapping
 	if mapping, exists := typeMapping[defType]; exists {
-		return mapping.jsonType, mapping.format, mapping.skipNested
-	}
-
-	// Handle maps - represent as objects
-	if strings.HasPrefix(defType, "map[") {
-		return "object", "", true
+		return mapping.jsonType, mapping.format
 	}
 
 	// Handle channels - not directly representable in JSON Schema
 	if strings.HasPrefix(defType, "chan ") || strings.HasPrefix(defType, "<-chan ") || strings.HasPrefix(defType, "chan<- ") {
-		return "string", "", true // Represent as string with channel info
+		return "string", "" // Represent as string with channel info
 	}
 
 	// Handle functions - not directly representable in JSON Schema
 	if strings.HasPrefix(defType, "func(") {
-		return "string", "", true // Could represent as string description
+		return "string", "" // Could represent as string description
 	}
 
-	// Handle complex types
+	// Handle named types by their underlying kind
 	if refType != nil {
-		f := *refType
-		kind := f.Kind()
+		kind := (*refType).Kind()
 
-		// Handle interface type
+		// Handle interface type - should accept any value
 		if kind == reflect.Interface {
-			return "", "", true
+			return "", ""
 		}
 
-		if kind.String() != "ptr" {
-			return convertToEventName(kind.String(), nil)
-		}
-		if f.Elem().Kind() != reflect.Struct {
-			return convertToEventName(f.Elem().Kind().String(), nil)
-		}
+		return convertToEventName(kind.String(), nil)
 	}
 
-	return defType, "", false
+	return defType, ""
 }
@@ -2,9 +2,13 @@ package schematic
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sadrishehu/schematic/schematic/internal/billing"
 	"github.com/stretchr/testify/require"
 )
 
@@ -76,20 +80,20 @@ func TestEdgeCases(t *testing.T) {
 
 	// Test map field
 	require.Contains(t, properties, "map_field")
-	require.Equal(t, "object", properties["map_field"].Type)
+	require.Equal(t, "object", properties["map_field"].Type.String())
 
 	// Test interface{} field - should accept any type
 	require.Contains(t, properties, "interface_field")
-	require.Equal(t, "", properties["interface_field"].Type) // No type constraint
+	require.Equal(t, "", properties["interface_field"].Type.String()) // No type constraint
 
 	// Test time field
 	require.Contains(t, properties, "time_field")
-	require.Equal(t, "string", properties["time_field"].Type)
+	require.Equal(t, "string", properties["time_field"].Type.String())
 	require.Equal(t, "date-time", properties["time_field"].Format)
 
 	// Test byte slice
 	require.Contains(t, properties, "byte_slice")
-	require.Equal(t, "string", properties["byte_slice"].Type)
+	require.Equal(t, "string", properties["byte_slice"].Type.String())
 	require.Equal(t, "byte", properties["byte_slice"].Format)
 
 	// Test skipped field should not be present
@@ -97,7 +101,7 @@ func TestEdgeCases(t *testing.T) {
 
 	// Test uint field
 	require.Contains(t, properties, "uint_field")
-	require.Equal(t, "integer", properties["uint_field"].Type)
+	require.Equal(t, "integer", properties["uint_field"].Type.String())
 }
 
 func TestRecursiveStructs(t *testing.T) {
@@ -107,7 +111,7 @@ func TestRecursiveStructs(t *testing.T) {
 	require.Contains(t, properties, "children")
 
 	// Children should be an array
-	require.Equal(t, "array", properties["children"].Type)
+	require.Equal(t, "array", properties["children"].Type.String())
 	require.NotNil(t, properties["children"].Items)
 }
 
@@ -120,7 +124,7 @@ func TestSnakeCaseConversion(t *testing.T) {
 		IOHandler       string // Should become i_o_handler
 	}
 
-	properties := GenerateProperties(TestStruct{})
+	properties := GenerateProperties(TestStruct{}, WithNamingStrategy(SnakeCase))
 
 	// Field with explicit JSON tag should use the tag
 	require.Contains(t, properties, "explicit_json_tag")
@@ -151,3 +155,646 @@ func TestGenerateRequired(t *testing.T) {
 	expectedTagsRequired := []string{"event_name", "event_version", "event_id"}
 	require.ElementsMatch(t, expectedTagsRequired, tagsRequired)
 }
+
+type ConstrainedStruct struct {
+	Score    int      `json:"score" jsonschema:"minimum=0,maximum=100"`
+	Ratio    float64  `json:"ratio" jsonschema:"exclusiveMinimum=0,multipleOf=0.5"`
+	Slug     string   `json:"slug" jsonschema:"pattern=^[a-z]{1,3}$,minLength=1,maxLength=64"`
+	Status   string   `json:"status" jsonschema:"enum=active|inactive"`
+	Priority int      `json:"priority" jsonschema:"enum=1|2|3"`
+	Labels   []string `json:"labels" jsonschema:"minItems=1,maxItems=5,uniqueItems,enum=a|b|c"`
+	Contact  string   `json:"contact" jsonschema:"format=email"`
+}
+
+func TestConstraintTags(t *testing.T) {
+	properties := GenerateProperties(ConstrainedStruct{})
+
+	require.Equal(t, 0.0, *properties["score"].Minimum)
+	require.Equal(t, 100.0, *properties["score"].Maximum)
+
+	require.Equal(t, 0.0, *properties["ratio"].ExclusiveMinimum)
+	require.Equal(t, 0.5, *properties["ratio"].MultipleOf)
+
+	// Commas inside a value are kept when not followed by a keyword
+	require.Equal(t, "^[a-z]{1,3}$", properties["slug"].Pattern)
+	require.Equal(t, 1, *properties["slug"].MinLength)
+	require.Equal(t, 64, *properties["slug"].MaxLength)
+
+	// Enum values follow the JSON type of the field
+	require.Equal(t, []interface{}{"active", "inactive"}, properties["status"].Enum)
+	require.Equal(t, []interface{}{int64(1), int64(2), int64(3)}, properties["priority"].Enum)
+
+	// Array keywords stay on the array, item keywords move to items
+	labels := properties["labels"]
+	require.Equal(t, 1, *labels.MinItems)
+	require.Equal(t, 5, *labels.MaxItems)
+	require.True(t, labels.UniqueItems)
+	require.Nil(t, labels.Enum)
+	require.Equal(t, []interface{}{"a", "b", "c"}, labels.Items.Enum)
+
+	require.Equal(t, "email", properties["contact"].Format)
+}
+
+func TestInvalidConstraintTag(t *testing.T) {
+	type InvalidStruct struct {
+		Field int    `json:"field" jsonschema:"minimum=low,maximum=10"`
+		Name  string `json:"name" jsonschema:"title=Name,description=The name, in full,required,minLength=1"`
+		Code  string `json:"code" jsonschema:"pattern=a,b,title=Code"`
+	}
+
+	var warnings []string
+	warnf := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+	properties := GenerateProperties(InvalidStruct{}, WithWarnings(warnf))
+
+	// Invalid values and the keywords of other libraries are reported and skipped
+	require.Nil(t, properties["field"].Minimum)
+	require.Equal(t, 10.0, *properties["field"].Maximum)
+	require.Equal(t, 1, *properties["name"].MinLength)
+	require.Equal(t, "a,b", properties["code"].Pattern)
+	require.Equal(t, []string{
+		`schematic: ignoring jsonschema tag on field Field: invalid value for minimum: strconv.ParseFloat: parsing "low": invalid syntax`,
+		`schematic: ignoring jsonschema tag on field Name: unknown keyword "title"`,
+		`schematic: ignoring jsonschema tag on field Name: unknown keyword "description"`,
+		`schematic: ignoring jsonschema tag on field Name: unknown keyword "required"`,
+		`schematic: ignoring jsonschema tag on field Code: unknown keyword "title"`,
+	}, warnings)
+
+	require.NotPanics(t, func() { GenerateProperties(InvalidStruct{}) })
+}
+
+type OrderStatus string
+
+type MapStruct struct {
+	Counts    map[string]int              `json:"counts"`
+	Orders    map[string]ValidationOrder  `json:"orders"`
+	Lines     map[string][]SimpleStruct   `json:"lines"`
+	ByID      map[int64]string            `json:"by_id"`
+	ByStatus  map[OrderStatus]bool        `json:"by_status"`
+	Anything  map[string]interface{}      `json:"anything"`
+	Optional  *map[string]float64         `json:"optional"`
+	Recursive map[string]RecursiveMapNode `json:"recursive"`
+}
+
+type RecursiveMapNode struct {
+	Name     string                      `json:"name"`
+	Children map[string]RecursiveMapNode `json:"children"`
+}
+
+func TestMapProperties(t *testing.T) {
+	schema := GenerateSchema(MapStruct{}, "Maps", "http://json-schema.org/draft-07/schema#")
+	properties := schema.Properties
+
+	counts := properties["counts"]
+	require.Equal(t, "object", counts.Type.String())
+	require.Equal(t, "integer", counts.AdditionalProperties.Type.String())
+	require.Nil(t, counts.PropertyNames)
+
+	// Struct values reuse the definition logic
+	orders := properties["orders"]
+	require.Equal(t, "#/$defs/ValidationOrder", orders.AdditionalProperties.Ref)
+	require.Contains(t, schema.Definitions, "ValidationOrder")
+
+	lines := properties["lines"]
+	require.Equal(t, "array", lines.AdditionalProperties.Type.String())
+	require.Equal(t, "#/$defs/SimpleStruct", lines.AdditionalProperties.Items.Ref)
+	require.Contains(t, schema.Definitions["SimpleStruct"].Properties, "field_string")
+
+	require.Equal(t, "^-?[0-9]+$", properties["by_id"].PropertyNames.Pattern)
+	require.Equal(t, "string", properties["by_id"].AdditionalProperties.Type.String())
+
+	require.Equal(t, "string", properties["by_status"].PropertyNames.Type.String())
+	require.Equal(t, "boolean", properties["by_status"].AdditionalProperties.Type.String())
+
+	require.Nil(t, properties["anything"].AdditionalProperties)
+
+	require.Equal(t, "object", properties["optional"].Type.String())
+	require.Equal(t, "number", properties["optional"].AdditionalProperties.Type.String())
+
+	// Recursive map values refer to their definition
+	require.Equal(t, "#/$defs/RecursiveMapNode", properties["recursive"].AdditionalProperties.Ref)
+	require.Equal(t, "#/$defs/RecursiveMapNode", schema.Definitions["RecursiveMapNode"].Properties["children"].AdditionalProperties.Ref)
+
+	required := `"orders": {}, "lines": {}, "by_status": {}, "anything": {}, "recursive": {}`
+	require.Empty(t, Validate(schema, []byte(`{"counts": {"a": 1}, "by_id": {"-12": "x"}, `+required+`}`)))
+
+	errs := Validate(schema, []byte(`{"counts": {"a": "1"}, "by_id": {"x": "y"}, `+required+`}`))
+	require.Len(t, errs, 2)
+	require.Equal(t, "/by_id/x", errs[0].Path)
+	require.Equal(t, "/counts/a", errs[1].Path)
+}
+
+type NullableStruct struct {
+	Name     string        `json:"name"`
+	Nickname *string       `json:"nickname"`
+	Level    *int          `json:"level" jsonschema:"enum=1|2"`
+	Simple   *SimpleStruct `json:"simple"`
+	Refs     []*string     `json:"refs"`
+	Scores   map[string]*float64
+}
+
+func TestNullablePointers(t *testing.T) {
+	schema := GenerateSchema(NullableStruct{}, "Nullable", "http://json-schema.org/draft-07/schema#", WithNullablePointers())
+	properties := schema.Properties
+
+	require.Equal(t, SchemaType{"string"}, properties["name"].Type)
+	require.Equal(t, SchemaType{"string", "null"}, properties["nickname"].Type)
+	require.Equal(t, []interface{}{int64(1), int64(2), nil}, properties["level"].Enum)
+	require.Equal(t, []PropertyDefinition{{Ref: "#/$defs/SimpleStruct"}, {Type: SchemaType{"null"}}}, properties["simple"].AnyOf)
+	require.Equal(t, SchemaType{"array"}, properties["refs"].Type)
+	require.Equal(t, SchemaType{"string", "null"}, properties["refs"].Items.Type)
+	require.Equal(t, SchemaType{"number", "null"}, properties["Scores"].AdditionalProperties.Type)
+
+	marshaled, err := json.Marshal(properties["nickname"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":["string","null"],"description":"Nickname"}`, string(marshaled))
+
+	payload := `{"name": "a", "nickname": null, "level": null, "simple": null, "refs": ["a", null], "Scores": {"x": null}}`
+	require.Empty(t, Validate(schema, []byte(payload)))
+
+	// Without the option pointers keep the plain type
+	properties = GenerateProperties(NullableStruct{})
+	require.Equal(t, SchemaType{"string"}, properties["nickname"].Type)
+}
+
+func TestSchemaTypeJSON(t *testing.T) {
+	var property PropertyDefinition
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"string"}`), &property))
+	require.Equal(t, SchemaType{"string"}, property.Type)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type":["integer","null"]}`), &property))
+	require.Equal(t, SchemaType{"integer", "null"}, property.Type)
+	require.Equal(t, "integer", property.Type.Primary())
+
+	require.Error(t, json.Unmarshal([]byte(`{"type":1}`), &property))
+
+	marshaled, err := json.Marshal(PropertyDefinition{Type: SchemaType{"string"}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"string"}`, string(marshaled))
+}
+
+type BaseEvent struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	Source    string `json:"source,omitempty"`
+}
+
+type EventMeta struct {
+	Trace   string `json:"trace"`
+	Comment string `json:"comment"`
+}
+
+type AuditInfo struct {
+	Comment string `json:"comment"`
+	Actor   string `json:"actor"`
+}
+
+type TaggedSource struct {
+	Source string `json:"source"`
+}
+
+type EmbeddedEvent struct {
+	BaseEvent
+	*EventMeta
+	AuditInfo
+	Nested TaggedSource `json:"nested"`
+	Named  BaseEvent    `json:"named"`
+	Name   string       `json:"name"`
+	ID     int          `json:"id"`
+}
+
+func TestEmbeddedStructs(t *testing.T) {
+	properties := GenerateProperties(EmbeddedEvent{})
+
+	// Embedded fields are promoted rather than nested
+	require.NotContains(t, properties, "base_event")
+	require.NotContains(t, properties, "event_meta")
+	require.Contains(t, properties, "created_at")
+	require.Contains(t, properties, "source")
+	require.Contains(t, properties, "trace")
+	require.Contains(t, properties, "actor")
+
+	// The outer field hides the promoted one
+	require.Equal(t, "integer", properties["id"].Type.String())
+
+	// Conflicting fields on the same depth are dropped like in encoding/json
+	require.NotContains(t, properties, "comment")
+
+	// Embedded structs with a json name stay nested
+	require.Equal(t, "#/$defs/BaseEvent", properties["named"].Ref)
+
+	required := GenerateRequired(EmbeddedEvent{}, nil)
+	require.ElementsMatch(t, []string{"id", "created_at", "actor", "nested", "named", "name"}, required)
+}
+
+func TestEmbeddedTagPrecedence(t *testing.T) {
+	type Untagged struct {
+		Source string `json:",omitempty"`
+	}
+	type Event struct {
+		Untagged
+		TaggedSource
+	}
+
+	properties := GenerateProperties(Event{})
+	require.Len(t, properties, 2)
+	require.Equal(t, "Source", properties["source"].Description)
+	require.Contains(t, properties, "Source")
+
+	fields := structFields(reflect.TypeOf(Event{}), GoFieldNames)
+	require.Len(t, fields, 2)
+	require.Equal(t, []int{0, 0}, fields[0].Index)
+	require.Equal(t, []int{1, 0}, fields[1].Index)
+
+	// With a strategy mapping both to the same name the tagged one wins
+	fields = structFields(reflect.TypeOf(Event{}), SnakeCase)
+	require.Len(t, fields, 1)
+	require.Equal(t, []int{1, 0}, fields[0].Index)
+}
+
+func TestNamingStrategies(t *testing.T) {
+	type Named struct {
+		UserID     string
+		HTTPStatus int    `json:",omitempty"`
+		Tagged     string `json:"explicit"`
+		internal   string
+	}
+	_ = Named{internal: ""}
+
+	// The default matches encoding/json
+	properties := GenerateProperties(Named{})
+	marshaled, err := json.Marshal(Named{HTTPStatus: 200})
+	require.NoError(t, err)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(marshaled, &payload))
+	for name := range payload {
+		require.Contains(t, properties, name)
+	}
+	require.Len(t, properties, len(payload))
+	require.NotContains(t, properties, "internal")
+
+	require.Equal(t, []string{"UserID", "explicit"}, GenerateRequired(Named{}, nil))
+
+	properties = GenerateProperties(Named{}, WithNamingStrategy(CamelCase))
+	require.Contains(t, properties, "userID")
+	require.Contains(t, properties, "httpStatus")
+	require.Contains(t, properties, "explicit")
+
+	properties = GenerateProperties(Named{}, WithNamingStrategy(KebabCase))
+	require.Contains(t, properties, "user-i-d")
+
+	upper := NamingStrategy(strings.ToUpper)
+	schema := GenerateSchema(Named{}, "Named", "", WithNamingStrategy(upper))
+	require.Contains(t, schema.Properties, "USERID")
+	require.Equal(t, []string{"USERID", "explicit"}, schema.Required)
+	require.Equal(t, []string{"USERID", "explicit"}, GenerateRequired(Named{}, nil, WithNamingStrategy(upper)))
+}
+
+type ArrayStruct struct {
+	Checksum [16]byte     `json:"checksum"`
+	Point    [3]float64   `json:"point"`
+	Corners  [2]EventMeta `json:"corners"`
+	Optional *[2]string   `json:"optional"`
+	Bytes    []byte       `json:"bytes"`
+}
+
+func TestFixedSizeArrays(t *testing.T) {
+	schema := GenerateSchema(ArrayStruct{}, "Arrays", "")
+	properties := schema.Properties
+
+	// [N]byte is encoded by encoding/json as an array of numbers, unlike []byte
+	checksum := properties["checksum"]
+	require.Equal(t, SchemaType{"array"}, checksum.Type)
+	require.Equal(t, SchemaType{"integer"}, checksum.Items.Type)
+	require.Equal(t, 16, *checksum.MinItems)
+	require.Equal(t, 16, *checksum.MaxItems)
+	require.Equal(t, SchemaType{"string"}, properties["bytes"].Type)
+
+	point := properties["point"]
+	require.Equal(t, SchemaType{"number"}, point.Items.Type)
+	require.Equal(t, 3, *point.MinItems)
+	require.Equal(t, 3, *point.MaxItems)
+
+	corners := properties["corners"]
+	require.Equal(t, SchemaType{"object"}, corners.Items.Type)
+	require.Contains(t, corners.Items.Properties, "trace")
+	require.Equal(t, []string{"trace", "comment"}, corners.Items.Required)
+
+	optional := properties["optional"]
+	require.Equal(t, SchemaType{"string"}, optional.Items.Type)
+	require.Equal(t, 2, *optional.MaxItems)
+
+	// Arrays are never null, so they are required like other values
+	require.Contains(t, schema.Required, "point")
+	require.NotContains(t, schema.Required, "bytes")
+
+	checksumJSON, err := json.Marshal(ArrayStruct{}.Checksum)
+	require.NoError(t, err)
+	require.Empty(t, Validate(schema, []byte(`{"checksum": `+string(checksumJSON)+`, "point": [1, 2, 3], "corners": [{"trace": "a", "comment": ""}, {"trace": "b", "comment": ""}]}`)))
+
+	errs := Validate(schema, []byte(`{"checksum": [0], "point": [1, 2, 3, 4], "corners": []}`))
+	require.Equal(t, []string{"/checksum", "/corners", "/point"}, []string{errs[0].Path, errs[1].Path, errs[2].Path})
+}
+
+type NestedCollections struct {
+	Grid     [][]string                     `json:"grid" jsonschema:"minItems=1"`
+	Cube     [2][2][2]int                   `json:"cube"`
+	Rows     []map[string]int               `json:"rows"`
+	Deep     map[string]map[int][]*float64  `json:"deep"`
+	Pointers *[][]*EventMeta                `json:"pointers"`
+	Anything []interface{}                  `json:"anything"`
+	Blobs    [][]byte                       `json:"blobs"`
+	Times    map[string][]time.Time         `json:"times"`
+	Orders   []map[string]ValidationOrder   `json:"orders"`
+	Indexed  map[string]map[string]EventTag `json:"indexed"`
+}
+
+type EventTag struct {
+	Key string `json:"key"`
+}
+
+func TestNestedCollections(t *testing.T) {
+	schema := GenerateSchema(NestedCollections{}, "Collections", "", WithNullablePointers())
+	properties := schema.Properties
+
+	grid := properties["grid"]
+	require.Equal(t, SchemaType{"array"}, grid.Type)
+	require.Equal(t, 1, *grid.MinItems)
+	require.Equal(t, SchemaType{"array"}, grid.Items.Type)
+	require.Equal(t, SchemaType{"string"}, grid.Items.Items.Type)
+
+	cube := properties["cube"]
+	require.Equal(t, 2, *cube.Items.Items.MaxItems)
+	require.Equal(t, SchemaType{"integer"}, cube.Items.Items.Items.Type)
+
+	rows := properties["rows"]
+	require.Equal(t, SchemaType{"object"}, rows.Items.Type)
+	require.Equal(t, SchemaType{"integer"}, rows.Items.AdditionalProperties.Type)
+
+	deep := properties["deep"].AdditionalProperties
+	require.Equal(t, "^-?[0-9]+$", deep.PropertyNames.Pattern)
+	require.Equal(t, SchemaType{"array"}, deep.AdditionalProperties.Type)
+	require.Equal(t, SchemaType{"number", "null"}, deep.AdditionalProperties.Items.Type)
+
+	pointers := properties["pointers"]
+	require.Equal(t, SchemaType{"array", "null"}, pointers.Type)
+	require.Equal(t, SchemaType{"array"}, pointers.Items.Type)
+	require.Equal(t, SchemaType{"object", "null"}, pointers.Items.Items.Type)
+	require.Contains(t, pointers.Items.Items.Properties, "trace")
+
+	require.Empty(t, properties["anything"].Items.Type)
+
+	require.Equal(t, SchemaType{"string"}, properties["blobs"].Items.Type)
+	require.Equal(t, "byte", properties["blobs"].Items.Format)
+
+	require.Equal(t, "date-time", properties["times"].AdditionalProperties.Items.Format)
+
+	// Map values keep using definitions, also inside slices
+	require.Equal(t, "#/$defs/ValidationOrder", properties["orders"].Items.AdditionalProperties.Ref)
+	require.Equal(t, SchemaType{"object"}, properties["indexed"].AdditionalProperties.AdditionalProperties.Type)
+
+	payload := `{
+		"grid": [["a"], []], "cube": [[[1, 2], [3, 4]], [[5, 6], [7, 8]]], "rows": [{"a": 1}],
+		"deep": {"x": {"1": [1.5, null]}}, "pointers": [[{"trace": "t", "comment": "c"}, null]],
+		"anything": [1, "a", null], "blobs": ["AQI="], "times": {"a": ["2023-01-01T00:00:00Z"]},
+		"orders": [], "indexed": {"a": {"b": {"key": "k"}}}
+	}`
+	require.Empty(t, Validate(schema, []byte(payload)))
+
+	errs := Validate(schema, []byte(`{
+		"grid": [[1]], "cube": [[[1, 2], [3, 4]], [[5, 6], [7]]], "rows": [{"a": "b"}],
+		"deep": {"x": {"y": []}}, "pointers": null, "anything": [], "blobs": [], "times": {},
+		"orders": [], "indexed": {"a": {"b": {}}}
+	}`))
+	paths := []string{}
+	for _, err := range errs {
+		paths = append(paths, err.Path)
+	}
+	require.Equal(t, []string{"/cube/1/1", "/deep/x/y", "/grid/0/0", "/indexed/a/b", "/rows/0/a"}, paths)
+}
+
+type Comment struct {
+	ID      string    `json:"id"`
+	Body    string    `json:"body"`
+	Replies []Comment `json:"replies"`
+}
+
+type Thread struct {
+	Title    string    `json:"title"`
+	Comments []Comment `json:"comments"`
+}
+
+type Employee struct {
+	Name       string      `json:"name"`
+	Manager    *Employee   `json:"manager,omitempty"`
+	Reports    []*Employee `json:"reports"`
+	Department *Department `json:"department,omitempty"`
+}
+
+type Department struct {
+	Name    string     `json:"name"`
+	Head    Employee   `json:"head"`
+	Members []Employee `json:"members"`
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	schema := GenerateSchema(Thread{}, "Thread", "")
+
+	// Items of slices are inlined unless the type is recursive
+	require.Equal(t, "#/$defs/Comment", schema.Properties["comments"].Items.Ref)
+	comment := schema.Definitions["Comment"]
+	require.Equal(t, []string{"id", "body"}, comment.Required)
+	require.Equal(t, "#/$defs/Comment", comment.Properties["replies"].Items.Ref)
+
+	payload := `{"title": "t", "comments": [{"id": "1", "body": "a", "replies": [
+		{"id": "2", "body": "b", "replies": [{"id": "3", "body": "c", "replies": [{"id": 4, "body": "d"}]}]}
+	]}]}`
+	errs := Validate(schema, []byte(payload))
+	require.Len(t, errs, 1)
+	require.Equal(t, "/comments/0/replies/0/replies/0/replies/0/id", errs[0].Path)
+}
+
+func TestRecursiveRoot(t *testing.T) {
+	schema := GenerateSchema(Comment{}, "Comment", "")
+
+	require.Equal(t, "#/$defs/Comment", schema.Properties["replies"].Items.Ref)
+	require.Equal(t, schema.Properties, schema.Definitions["Comment"].Properties)
+	require.Empty(t, Validate(schema, []byte(`{"id": "1", "body": "a", "replies": [{"id": "2", "body": "b"}]}`)))
+}
+
+func TestMutuallyRecursiveTypes(t *testing.T) {
+	schema := GenerateSchema(Department{}, "Department", "", WithNullablePointers())
+
+	require.Equal(t, "#/$defs/Employee", schema.Properties["head"].Ref)
+	require.Equal(t, "#/$defs/Employee", schema.Properties["members"].Items.Ref)
+	require.Contains(t, schema.Definitions, "Department")
+
+	employee := schema.Definitions["Employee"]
+	require.Equal(t, []PropertyDefinition{{Ref: "#/$defs/Employee"}, {Type: SchemaType{"null"}}}, employee.Properties["manager"].AnyOf)
+	require.Equal(t, []PropertyDefinition{{Ref: "#/$defs/Employee"}, {Type: SchemaType{"null"}}}, employee.Properties["reports"].Items.AnyOf)
+	require.Equal(t, "#/$defs/Department", employee.Properties["department"].AnyOf[0].Ref)
+
+	payload := `{"name": "eng", "members": [], "head": {"name": "ada", "reports": [
+		{"name": "bob", "manager": {"name": "ada", "reports": []}, "reports": [null]},
+		{"name": "eve", "reports": [], "department": {"name": "ops", "members": [], "head": {"reports": []}}}
+	]}}`
+	errs := Validate(schema, []byte(payload))
+	require.Len(t, errs, 1)
+	// The invalid head is nested in the anyOf of a nullable pointer
+	require.Equal(t, "/head/reports/1", errs[0].Path)
+}
+
+type Address struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+	Zip    string `json:"zip"`
+}
+
+type Page[T any] struct {
+	Items  []T    `json:"items"`
+	Total  int    `json:"total"`
+	Cursor string `json:"cursor"`
+}
+
+type Shipment struct {
+	Billing  billing.Address       `json:"billing"`
+	Shipping Address               `json:"shipping"`
+	Orders   Page[ValidationOrder] `json:"orders"`
+	Carrier  struct {
+		Name    string `json:"name"`
+		Service string `json:"service"`
+		Contact struct {
+			Email string `json:"email"`
+			Phone string `json:"phone"`
+			Hours string `json:"hours"`
+		} `json:"contact"`
+	} `json:"carrier"`
+}
+
+func TestDefinitionNames(t *testing.T) {
+	schema := GenerateSchema(Shipment{}, "Shipment", "")
+
+	// Types sharing a name are qualified by their package, others keep their short name
+	require.Equal(t, "#/$defs/billing.Address", schema.Properties["billing"].Ref)
+	require.Equal(t, "#/$defs/schematic.Address", schema.Properties["shipping"].Ref)
+	require.Equal(t, "#/$defs/Page_ValidationOrder", schema.Properties["orders"].Ref)
+	require.Contains(t, schema.Definitions, "ValidationItem")
+
+	// Anonymous structs are named after the fields leading to them
+	require.Equal(t, "#/$defs/ShipmentCarrier", schema.Properties["carrier"].Ref)
+	require.Equal(t, "#/$defs/ShipmentCarrierContact", schema.Definitions["ShipmentCarrier"].Properties["contact"].Ref)
+
+	// The names don't depend on the order the types are encountered in
+	for i := 0; i < 10; i++ {
+		require.Equal(t, schema, GenerateSchema(Shipment{}, "Shipment", ""))
+	}
+
+	payload := `{"billing": {"name": "a", "line1": "b", "country": "c"}, "shipping": {"street": "a", "city": "b", "zip": "c"},
+		"orders": {"items": [], "total": 0, "cursor": ""}, "carrier": {"name": "a", "service": "b", "contact": {"email": "a", "phone": "b"}}}`
+	errs := Validate(schema, []byte(payload))
+	require.Len(t, errs, 1)
+	require.Equal(t, "/carrier/contact", errs[0].Path)
+}
+
+func TestDefinitionNaming(t *testing.T) {
+	schema := GenerateSchema(Shipment{}, "Shipment", "", WithDefinitionNaming(PackageDefinitionNames))
+	require.Equal(t, "#/$defs/schematic.Page_schematic.ValidationOrder", schema.Properties["orders"].Ref)
+	require.Equal(t, "#/$defs/schematic.ShipmentCarrier", schema.Properties["carrier"].Ref)
+
+	schema = GenerateSchema(Shipment{}, "Shipment", "", WithDefinitionNaming(FullPathDefinitionNames))
+	require.Contains(t, schema.Definitions, "github.com/sadrishehu/schematic/schematic/internal/billing.Address")
+	require.Equal(t, "#/$defs/github.com~1sadrishehu~1schematic~1schematic~1internal~1billing.Address", schema.Properties["billing"].Ref)
+
+	errs := Validate(schema, []byte(`{"billing": {}}`))
+	require.Contains(t, errs, ValidationError{Path: "/billing", Message: `missing required property "name"`})
+}
+
+func TestIndistinguishableDefinitionNames(t *testing.T) {
+	type Local struct {
+		A, B, C string
+	}
+	type outerLocal = Local
+	{
+		type Local struct {
+			X, Y, Z int
+		}
+		type Locals struct {
+			First  outerLocal `json:"first"`
+			Second Local      `json:"second"`
+		}
+
+		// Types declared in functions share their name and package, so they are numbered
+		schema := GenerateSchema(Locals{}, "Locals", "")
+		require.Equal(t, "#/$defs/Local", schema.Properties["first"].Ref)
+		require.Equal(t, "#/$defs/Local2", schema.Properties["second"].Ref)
+		require.Contains(t, schema.Definitions["Local2"].Properties, "X")
+	}
+}
+
+type Envelope[T any] struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Payload T      `json:"payload"`
+}
+
+type Pair[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+	Note  string
+}
+
+type GenericEvents struct {
+	Orders    Envelope[Page[ValidationOrder]]        `json:"orders"`
+	Page      Page[ValidationOrder]                  `json:"page"`
+	Addresses Envelope[Page[Address]]                `json:"addresses"`
+	Billing   Page[billing.Address]                  `json:"billing"`
+	Items     Envelope[[]*ValidationItem]            `json:"items"`
+	Pairs     []Pair[string, Envelope[SimpleStruct]] `json:"pairs"`
+}
+
+func TestGenericDefinitions(t *testing.T) {
+	schema := GenerateSchema(GenericEvents{}, "Generic Events", "", WithDefinitionPolicy(NamedDefinitions))
+	properties := schema.Properties
+
+	require.Equal(t, "#/$defs/Envelope_Page_ValidationOrder", properties["orders"].Ref)
+	require.Equal(t, "#/$defs/Envelope_SlicePtrValidationItem", properties["items"].Ref)
+	require.Equal(t, "#/$defs/Pair_string_Envelope_SimpleStruct", properties["pairs"].Items.Ref)
+
+	// The same instantiation is a single definition wherever it is used
+	require.Equal(t, "#/$defs/Page_ValidationOrder", properties["page"].Ref)
+	require.Equal(t, "#/$defs/Page_ValidationOrder", schema.Definitions["Envelope_Page_ValidationOrder"].Properties["payload"].Ref)
+	require.Equal(t, "#/$defs/ValidationOrder", schema.Definitions["Page_ValidationOrder"].Properties["items"].Items.Ref)
+	require.Equal(t, "#/$defs/SimpleStruct", schema.Definitions["Envelope_SimpleStruct"].Properties["payload"].Ref)
+
+	// Type arguments sharing a name are qualified by their package
+	require.Equal(t, "#/$defs/schematic.Page_billing.Address", properties["billing"].Ref)
+	require.Equal(t, "#/$defs/schematic.Page_schematic.Address", schema.Definitions["Envelope_Page_Address"].Properties["payload"].Ref)
+
+	for name := range schema.Definitions {
+		require.NotContains(t, name, "[")
+		require.NotContains(t, name, "/")
+	}
+
+	payload := `{
+		"orders": {"id": "1", "type": "orders", "payload": {"items": [{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "status": "open",
+			"created_at": "2023-01-01T00:00:00Z", "item": {"sku": "ABC-1", "quantity": 1, "Price": 2}}], "total": 1, "cursor": ""}},
+		"page": {"items": [], "total": 0, "cursor": ""},
+		"addresses": {"id": "2", "type": "addresses", "payload": {"items": [{"street": "a", "city": "b", "zip": "c"}], "total": 1, "cursor": ""}},
+		"billing": {"items": [{"name": "a", "line1": "b", "country": "c"}], "total": 1, "cursor": ""},
+		"items": {"id": "3", "type": "items", "payload": [{"sku": "ABC-1", "quantity": 1, "Price": 2}]},
+		"pairs": [{"key": "k", "Note": "", "value": {"id": "4", "type": "simple", "payload": {"field_string": "a", "field_int": 1,
+			"field_int32": 1, "field_int64": 1, "field_float32": 1, "field_float64": 1}}}]
+	}`
+	require.Empty(t, Validate(schema, []byte(payload)))
+
+	errs := Validate(schema, []byte(strings.Replace(payload, `"city": "b"`, `"city": 1`, 1)))
+	require.Len(t, errs, 1)
+	require.Equal(t, "/addresses/payload/items/0/city", errs[0].Path)
+
+	// Full paths are escaped in references
+	schema = GenerateSchema(GenericEvents{}, "Generic Events", "", WithDefinitionNaming(FullPathDefinitionNames))
+	require.Equal(t, "#/$defs/github.com~1sadrishehu~1schematic~1schematic.Page_github.com~1sadrishehu~1schematic~1schematic.ValidationOrder", schema.Properties["page"].Ref)
+	require.Empty(t, Validate(schema, []byte(payload)))
+}
@@ -0,0 +1,380 @@
+package schematic
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goInitialisms are the words written in upper case in Go field names, e.g. "ID" for "id"
+var goInitialisms = map[string]bool{
+	"ID": true, "URL": true, "URI": true, "UUID": true, "API": true, "HTTP": true,
+	"JSON": true, "IP": true, "SKU": true, "SQL": true, "HTML": true, "XML": true,
+}
+
+// goGenerator tracks state while Go source is generated from schemas
+type goGenerator struct {
+	b       strings.Builder
+	imports map[string]bool
+	// definitions holds the definitions of all schemas and types the names of the
+	// Go types declared for them
+	definitions map[string]PropertyDefinition
+	types       map[string]string
+}
+
+// GenerateGo creates gofmt'd Go source declaring a struct for every schema, named
+// after its title, and a type for every definition. Required properties become plain
+// fields, optional ones fields with omitempty, nullable ones pointers and arrays
+// slices, following the conventions of GenerateRequired, so that the schema of the
+// generated structs matches the original. Fields are named after their JSON names,
+// keeping the Go names GenerateSchema writes as descriptions when they spell the same
+// words, and validation keywords are kept in `jsonschema` tags. Definitions with the
+// same name in several schemas must be identical apart from their description.
+func GenerateGo(pkg string, schemas ...Schema) ([]byte, error) {
+	g := &goGenerator{
+		imports:     map[string]bool{},
+		definitions: map[string]PropertyDefinition{},
+		types:       map[string]string{},
+	}
+
+	taken := map[string]bool{}
+	for _, schema := range schemas {
+		for name, def := range schema.Definitions {
+			if existing, ok := g.definitions[name]; ok {
+				if !sameDefinition(existing, def) {
+					return nil, fmt.Errorf("definition %s differs between schemas", name)
+				}
+				continue
+			}
+			g.definitions[name] = def
+		}
+	}
+
+	names := make([]string, 0, len(g.definitions))
+	for name := range g.definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.types[name] = uniqueGoName(goTypeName(name), taken)
+	}
+
+	for _, schema := range schemas {
+		root := rootProperty(schema)
+		root.Type = typeOf("object")
+		name := goTypeName(schema.Title)
+		if taken[name] {
+			name += "Event"
+		}
+		name = uniqueGoName(name, taken)
+		fmt.Fprintf(&g.b, "\ntype %s %s\n", name, g.structType(root))
+	}
+
+	for _, name := range names {
+		def := g.definitions[name]
+		if isGoStruct(def) {
+			fmt.Fprintf(&g.b, "\ntype %s %s\n", g.types[name], g.structType(def))
+		} else {
+			goType, _ := g.goType(def)
+			fmt.Fprintf(&g.b, "\ntype %s %s\n", g.types[name], goType)
+		}
+	}
+
+	var source strings.Builder
+	source.WriteString("// Code generated by schematic. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&source, "package %s\n", pkg)
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for path := range g.imports {
+			imports = append(imports, strconv.Quote(path))
+		}
+		sort.Strings(imports)
+		fmt.Fprintf(&source, "\nimport (\n%s\n)\n", strings.Join(imports, "\n"))
+	}
+	source.WriteString(g.b.String())
+
+	formatted, err := format.Source([]byte(source.String()))
+	if err != nil {
+		return nil, fmt.Errorf("error while formatting generated source: %w", err)
+	}
+	return formatted, nil
+}
+
+// isGoStruct reports whether a property is described by a struct, i.e. an object
+// with properties and no schema for the others or the false schema rejecting them
+func isGoStruct(property PropertyDefinition) bool {
+	additional := property.AdditionalProperties
+	return property.Type.Primary() == "object" && property.Properties != nil && (additional == nil || rejectsAll(*additional))
+}
+
+// structType returns a struct type holding the properties of an object. Required
+// properties come first, in the order they are required, followed by the others in
+// alphabetical order.
+func (g *goGenerator) structType(property PropertyDefinition) string {
+	required := make(map[string]bool, len(property.Required))
+	var names []string
+	for _, name := range property.Required {
+		if _, ok := property.Properties[name]; ok && !required[name] {
+			names = append(names, name)
+		}
+		required[name] = true
+	}
+	var optional []string
+	for name := range property.Properties {
+		if !required[name] {
+			optional = append(optional, name)
+		}
+	}
+	sort.Strings(optional)
+	names = append(names, optional...)
+
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	fieldNames := map[string]bool{}
+	for _, name := range names {
+		field := property.Properties[name]
+		fieldName := goFieldName(field.Description, name, fieldNames)
+		goType, tag := g.fieldType(field, name, required[name])
+		fmt.Fprintf(&b, "%s %s `%s`\n", fieldName, goType, tag)
+	}
+	b.WriteString("}")
+
+	return b.String()
+}
+
+// fieldType returns the type and the struct tag of a field
+func (g *goGenerator) fieldType(property PropertyDefinition, name string, required bool) (string, string) {
+	goType, nullable := g.goType(property)
+	tag := name
+
+	nilable := strings.HasPrefix(goType, "[]") || strings.HasPrefix(goType, "map[") || goType == "interface{}"
+	switch {
+	case required:
+	case strings.HasPrefix(goType, "[]"):
+		// Slices are never required, whatever their tag
+	case nullable && !nilable:
+		goType = "*" + goType
+	case property.Ref != "" || strings.HasPrefix(goType, "struct {"):
+		goType = "*" + goType
+		tag += ",omitempty"
+	default:
+		tag += ",omitempty"
+	}
+
+	tags := "json:" + strconv.Quote(tag)
+	if constraints := goConstraints(property, goType); constraints != "" {
+		tags += " jsonschema:" + strconv.Quote(constraints)
+	}
+	return goType, tags
+}
+
+// goType returns the Go type describing a property and whether it accepts null
+func (g *goGenerator) goType(property PropertyDefinition) (string, bool) {
+	if property.Ref != "" {
+		return g.refType(property.Ref), false
+	}
+
+	if len(property.AnyOf) > 0 {
+		var branches []PropertyDefinition
+		nullable := false
+		for _, schema := range property.AnyOf {
+			if len(schema.Type) == 1 && schema.Type[0] == typeNull {
+				nullable = true
+				continue
+			}
+			branches = append(branches, schema)
+		}
+		if len(branches) != 1 {
+			return "interface{}", nullable
+		}
+		goType, branchNullable := g.goType(branches[0])
+		return goType, nullable || branchNullable
+	}
+
+	types := withoutNull(property.Type)
+	nullable := len(types) < len(property.Type)
+	if len(types) != 1 {
+		return "interface{}", nullable
+	}
+
+	switch types[0] {
+	case "string":
+		switch property.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time", nullable
+		case "byte":
+			return "[]byte", nullable
+		}
+		return "string", nullable
+	case "integer":
+		return "int", nullable
+	case "number":
+		return "float64", nullable
+	case "boolean":
+		return "bool", nullable
+	case typeArray:
+		items := "interface{}"
+		if property.Items != nil {
+			var itemsNullable bool
+			items, itemsNullable = g.goType(*property.Items)
+			if itemsNullable && !strings.HasPrefix(items, "[]") && !strings.HasPrefix(items, "map[") && items != "interface{}" {
+				items = "*" + items
+			}
+		}
+		if property.MinItems != nil && property.MaxItems != nil && *property.MinItems == *property.MaxItems {
+			return fmt.Sprintf("[%d]%s", *property.MinItems, items), nullable
+		}
+		return "[]" + items, nullable
+	case "object":
+		if isGoStruct(property) {
+			return g.structType(property), nullable
+		}
+		values := "interface{}"
+		if property.AdditionalProperties != nil {
+			var valuesNullable bool
+			values, valuesNullable = g.goType(*property.AdditionalProperties)
+			if valuesNullable && !strings.HasPrefix(values, "[]") && !strings.HasPrefix(values, "map[") && values != "interface{}" {
+				values = "*" + values
+			}
+		}
+		return "map[" + goMapKey(property.PropertyNames) + "]" + values, nullable
+	}
+
+	return "interface{}", nullable
+}
+
+// refType returns the Go type a reference to a definition refers to
+func (g *goGenerator) refType(ref string) string {
+	if !strings.HasPrefix(ref, defsRefPrefix) {
+		return "interface{}"
+	}
+	if name, ok := g.types[unescapePointer(strings.TrimPrefix(ref, defsRefPrefix))]; ok {
+		return name
+	}
+	return "interface{}"
+}
+
+// goMapKey returns the key type of a map from the propertyNames written for integer keys
+func goMapKey(names *PropertyDefinition) string {
+	if names == nil {
+		return "string"
+	}
+	switch names.Pattern {
+	case "^-?[0-9]+$":
+		return "int"
+	case "^[0-9]+$":
+		return "uint"
+	}
+	return "string"
+}
+
+// goConstraints returns the `jsonschema` tag keeping the validation keywords of a
+// property. For slices the keywords of the items are written to the tag, which
+// applies them to the items again.
+func goConstraints(property PropertyDefinition, goType string) string {
+	var entries []string
+	goType = strings.TrimPrefix(goType, "*")
+
+	fixed := strings.HasPrefix(goType, "[") && !strings.HasPrefix(goType, "[]")
+	if !fixed {
+		entries = appendIntConstraint(entries, "minItems", property.MinItems)
+		entries = appendIntConstraint(entries, "maxItems", property.MaxItems)
+	}
+	if property.UniqueItems {
+		entries = append(entries, "uniqueItems")
+	}
+
+	target := property
+	if property.Type.Primary() == typeArray && property.Items != nil {
+		target = *property.Items
+		goType = strings.TrimPrefix(goType[strings.Index(goType, "]")+1:], "*")
+	}
+
+	if len(target.Enum) > 0 {
+		values := make([]string, 0, len(target.Enum))
+		for _, value := range target.Enum {
+			if value != nil {
+				values = append(values, fmt.Sprint(value))
+			}
+		}
+		entries = append(entries, "enum="+strings.Join(values, "|"))
+	}
+	entries = appendFloatConstraint(entries, "minimum", target.Minimum)
+	entries = appendFloatConstraint(entries, "maximum", target.Maximum)
+	entries = appendFloatConstraint(entries, "exclusiveMinimum", target.ExclusiveMinimum)
+	entries = appendFloatConstraint(entries, "exclusiveMaximum", target.ExclusiveMaximum)
+	entries = appendFloatConstraint(entries, "multipleOf", target.MultipleOf)
+	entries = appendIntConstraint(entries, "minLength", target.MinLength)
+	entries = appendIntConstraint(entries, "maxLength", target.MaxLength)
+	if target.Pattern != "" && !strings.Contains(target.Pattern, "`") {
+		entries = append(entries, "pattern="+target.Pattern)
+	}
+	// The formats implied by the Go type are written by GenerateSchema anyway
+	if target.Format != "" && !(target.Format == "date-time" && goType == "time.Time") && !(target.Format == "byte" && goType == "[]byte") {
+		entries = append(entries, "format="+target.Format)
+	}
+
+	return strings.Join(entries, ",")
+}
+
+func appendIntConstraint(entries []string, keyword string, value *int) []string {
+	if value == nil {
+		return entries
+	}
+	return append(entries, keyword+"="+strconv.Itoa(*value))
+}
+
+func appendFloatConstraint(entries []string, keyword string, value *float64) []string {
+	if value == nil {
+		return entries
+	}
+	return append(entries, keyword+"="+strconv.FormatFloat(*value, 'g', -1, 64))
+}
+
+// goTypeName turns a definition name or a title into an exported Go type name
+func goTypeName(name string) string {
+	return pascalTypeName(strings.ReplaceAll(name, "$", " "))
+}
+
+// goFieldName names a field after its JSON name, e.g. "CreatedAt" for "created_at".
+// GenerateSchema describes fields by their Go name, which is kept when it spells the
+// same words, e.g. "Url" for "url"; other descriptions don't name the field.
+func goFieldName(description, jsonName string, taken map[string]bool) string {
+	var b strings.Builder
+	for _, word := range typeNameSeparator.Split(strings.NewReplacer("_", " ", "$", " ").Replace(jsonName), -1) {
+		if word == "" {
+			continue
+		}
+		if upper := strings.ToUpper(word); goInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "Field" + name
+	}
+	if token.IsIdentifier(description) && token.IsExported(description) && strings.EqualFold(description, name) {
+		name = description
+	}
+	return uniqueGoName(name, taken)
+}
+
+// uniqueGoName numbers a name already taken and marks the result as taken
+func uniqueGoName(name string, taken map[string]bool) string {
+	unique := name
+	for suffix := 2; taken[unique]; suffix++ {
+		unique = name + strconv.Itoa(suffix)
+	}
+	taken[unique] = true
+	return unique
+}
@@ -0,0 +1,167 @@
+package schematic
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type GoLine struct {
+	SKU      string   `json:"sku" jsonschema:"pattern=^[A-Z]{3}-[0-9]+$"`
+	Quantity int      `json:"quantity" jsonschema:"minimum=1"`
+	Tags     []string `json:"tags" jsonschema:"enum=a|b,maxItems=2"`
+}
+
+type GoOrder struct {
+	ID        string                `json:"id" jsonschema:"format=uuid"`
+	Status    string                `json:"status" jsonschema:"enum=open|closed"`
+	Line      GoLine                `json:"line"`
+	CreatedAt time.Time             `json:"created_at"`
+	Counts    map[int]float64       `json:"counts"`
+	Pair      [2]int                `json:"pair"`
+	Shipping  struct{ City string } `json:"shipping"`
+	Backup    *GoLine               `json:"backup"`
+	Blob      []byte                `json:"blob"`
+	Lines     []GoLine              `json:"lines"`
+	Nick      *string               `json:"nick"`
+	Note      string                `json:"note,omitempty"`
+}
+
+const goOrderSource = "// Code generated by schematic. DO NOT EDIT.\n" + `
+package events
+
+import (
+	"time"
+)
+
+type GoOrder struct {
+	ID        string          ` + "`json:\"id\" jsonschema:\"format=uuid\"`" + `
+	Status    string          ` + "`json:\"status\" jsonschema:\"enum=open|closed\"`" + `
+	Line      GoLine          ` + "`json:\"line\"`" + `
+	CreatedAt time.Time       ` + "`json:\"created_at\"`" + `
+	Counts    map[int]float64 ` + "`json:\"counts\"`" + `
+	Pair      [2]int          ` + "`json:\"pair\"`" + `
+	Shipping  struct {
+		City string ` + "`json:\"City\"`" + `
+	} ` + "`json:\"shipping\"`" + `
+	Backup *GoLine  ` + "`json:\"backup\"`" + `
+	Blob   []byte   ` + "`json:\"blob\"`" + `
+	Lines  []GoLine ` + "`json:\"lines\"`" + `
+	Nick   *string  ` + "`json:\"nick\"`" + `
+	Note   string   ` + "`json:\"note,omitempty\"`" + `
+}
+
+type GoLine struct {
+	SKU      string   ` + "`json:\"sku\" jsonschema:\"pattern=^[A-Z]{3}-[0-9]+$\"`" + `
+	Quantity int      ` + "`json:\"quantity\" jsonschema:\"minimum=1\"`" + `
+	Tags     []string ` + "`json:\"tags\" jsonschema:\"maxItems=2,enum=a|b\"`" + `
+}
+`
+
+func TestGenerateGo(t *testing.T) {
+	schema := GenerateSchema(GoOrder{}, "Go Order", string(Draft07), WithNullablePointers())
+
+	source, err := GenerateGo("events", schema)
+	require.NoError(t, err)
+	require.Equal(t, goOrderSource, string(source))
+
+	// A schema read back from its file generates the same source
+	marshaled, err := json.Marshal(schema)
+	require.NoError(t, err)
+	var decoded Schema
+	require.NoError(t, json.Unmarshal(marshaled, &decoded))
+
+	source, err = GenerateGo("events", decoded)
+	require.NoError(t, err)
+	require.Equal(t, goOrderSource, string(source))
+}
+
+func TestGenerateGoThirdPartySchema(t *testing.T) {
+	var schema Schema
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title": "payment.settled",
+		"type": "object",
+		"required": ["payment_id", "amount"],
+		"additionalProperties": false,
+		"properties": {
+			"payment_id": {"type": "string", "description": "The payment"},
+			"amount": {"$ref": "#/definitions/money"},
+			"reference-url": {"type": ["string", "null"], "format": "uri"},
+			"metadata": {"type": "object", "additionalProperties": {"type": "string"}}
+		},
+		"definitions": {
+			"money": {"type": "object", "required": ["units"], "properties": {"units": {"type": "integer", "minimum": 0}}}
+		}
+	}`), &schema))
+
+	source, err := GenerateGo("payments", schema)
+	require.NoError(t, err)
+	require.Equal(t, "// Code generated by schematic. DO NOT EDIT.\n"+`
+package payments
+
+type PaymentSettled struct {
+	PaymentID    string            `+"`json:\"payment_id\"`"+`
+	Amount       Money             `+"`json:\"amount\"`"+`
+	Metadata     map[string]string `+"`json:\"metadata,omitempty\"`"+`
+	ReferenceURL *string           `+"`json:\"reference-url\" jsonschema:\"format=uri\"`"+`
+}
+
+type Money struct {
+	Units int `+"`json:\"units\" jsonschema:\"minimum=0\"`"+`
+}
+`, string(source))
+}
+
+func TestGenerateGoConflictingDefinitions(t *testing.T) {
+	a := Schema{Title: "A", Definitions: map[string]PropertyDefinition{"Tags": {Type: typeOf("string")}}}
+	b := Schema{Title: "B", Definitions: map[string]PropertyDefinition{"Tags": {Type: typeOf("integer")}}}
+
+	_, err := GenerateGo("events", a, b)
+	require.ErrorContains(t, err, "definition Tags differs between schemas")
+}
+
+type GoReturn struct {
+	Returned GoLine `json:"returned"`
+}
+
+type GoExchange struct {
+	Replacement GoLine `json:"replacement"`
+}
+
+func TestGenerateGoSharedDefinitionUnderOtherFieldNames(t *testing.T) {
+	returned := GenerateSchema(GoReturn{}, "Go Return", string(Draft07), WithDefinitionPolicy(NamedDefinitions))
+	exchange := GenerateSchema(GoExchange{}, "Go Exchange", string(Draft07), WithDefinitionPolicy(NamedDefinitions))
+
+	// GoLine is described as Returned in one schema and Replacement in the other
+	source, err := GenerateGo("events", returned, exchange)
+	require.NoError(t, err)
+	require.Contains(t, string(source), "type GoLine struct {")
+	require.Contains(t, string(source), "Returned GoLine")
+	require.Contains(t, string(source), "Replacement GoLine")
+}
+
+func TestGenerateGoFieldNamesFromDescriptions(t *testing.T) {
+	var schema Schema
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"title": "invoice",
+		"type": "object",
+		"required": ["foo", "total", "url", "user_id"],
+		"properties": {
+			"foo": {"type": "string", "description": "Deprecated"},
+			"total": {"type": "number", "description": "Amount"},
+			"url": {"type": "string", "description": "Url"},
+			"user_id": {"type": "string", "description": "UserID"}
+		}
+	}`), &schema))
+
+	// Only descriptions spelling the JSON name, like the Go names written by GenerateSchema, name fields
+	source, err := GenerateGo("billing", schema)
+	require.NoError(t, err)
+	require.Contains(t, string(source), "\tFoo    string  `json:\"foo\"`\n")
+	require.Contains(t, string(source), "\tTotal  float64 `json:\"total\"`\n")
+	require.Contains(t, string(source), "\tUrl    string  `json:\"url\"`\n")
+	require.Contains(t, string(source), "\tUserID string  `json:\"user_id\"`\n")
+}
@@ -0,0 +1,146 @@
+package schematic
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+)
+
+// JSONSchemer is implemented by types that describe their own schema, typically
+// because they marshal a custom JSON representation. The returned definition is
+// used verbatim instead of reflecting the type.
+type JSONSchemer interface {
+	JSONSchema() PropertyDefinition
+}
+
+// ReflectJSONSchemer is the variant of JSONSchemer for types whose schema is
+// composed of the schemas of other types. The Reflector passed in generates those
+// sub-schemas with Property, sharing the definitions of the schema being built.
+type ReflectJSONSchemer interface {
+	JSONSchemaReflect(r *Reflector) PropertyDefinition
+}
+
+var (
+	jsonSchemerType        = reflect.TypeOf((*JSONSchemer)(nil)).Elem()
+	reflectJSONSchemerType = reflect.TypeOf((*ReflectJSONSchemer)(nil)).Elem()
+	jsonMarshalerType      = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType      = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Property creates the schema of a single Go value. When called from a
+// ReflectJSONSchemer the nested structs end up in the $defs of the schema being
+// generated, otherwise they are inlined. Recursive structs can't be inlined: on
+// their own they are referred to with a $ref to a definition Property can't
+// return, use PropertyWithDefinitions for them.
+func (r *Reflector) Property(object interface{}) PropertyDefinition {
+	t := reflect.TypeOf(object)
+	if t != nil && r.active != nil {
+		return r.active.buildTypeProperty(t, t.Name())
+	}
+
+	property, definitions := r.PropertyWithDefinitions(object)
+	if len(definitions) > 0 && r.Warnf != nil {
+		r.Warnf("schematic: %s is recursive, its schema refers to definitions only PropertyWithDefinitions returns", t)
+	}
+	return property
+}
+
+// PropertyWithDefinitions creates the schema of a single Go value with nested
+// structs inlined, along with the definitions of the recursive structs it refers
+// to, keyed by their name in $defs
+func (r *Reflector) PropertyWithDefinitions(object interface{}) (PropertyDefinition, map[string]PropertyDefinition) {
+	t := reflect.TypeOf(object)
+	if t == nil {
+		return PropertyDefinition{}, nil
+	}
+
+	standalone := *r
+	standalone.DefinitionPolicy = InlineDefinitions
+	ctx := standalone.newContext()
+	property := ctx.buildTypeProperty(t, t.Name())
+
+	resolver := ctx.newResolver(map[string]PropertyDefinition{"": property})
+	definitions := resolver.definitions()
+	if len(definitions) == 0 {
+		definitions = nil
+	}
+	return resolver.resolve(property), definitions
+}
+
+// customProperty returns the schema of a type that is not reflected: a registered
+// type mapping, the schema a type describes itself with, or the schema implied by
+// a custom JSON or text encoding
+func (ctx *schemaContext) customProperty(t reflect.Type) (PropertyDefinition, bool) {
+	if custom, ok := ctx.reflector.lookupType(t); ok {
+		return custom, true
+	}
+
+	// encoding/json calls the marshalers of the value it is given: methods with
+	// pointer receivers are only used for pointer fields
+	marshaled := t
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return PropertyDefinition{}, false
+	}
+
+	// Methods with value or pointer receivers are both callable on a new pointer
+	ptr := reflect.PointerTo(t)
+	switch {
+	case ptr.Implements(reflectJSONSchemerType):
+		hook := *ctx.reflector
+		hook.active = ctx
+		return reflect.New(t).Interface().(ReflectJSONSchemer).JSONSchemaReflect(&hook), true
+	case ptr.Implements(jsonSchemerType):
+		return reflect.New(t).Interface().(JSONSchemer).JSONSchema(), true
+	}
+
+	// Well known types such as time.Time implement the marshalers but have a
+	// more precise built-in mapping
+	if _, ok := typeMapping[t.String()]; ok {
+		return PropertyDefinition{}, false
+	}
+
+	// encoding/json prefers MarshalJSON over MarshalText. Without a schema hook
+	// nothing is known about its output, so any value is accepted.
+	switch {
+	case marshaled.Implements(jsonMarshalerType):
+		ctx.warnOnce(t, "schematic: %s implements json.Marshaler but not JSONSchemer, its schema is unconstrained", t)
+		return PropertyDefinition{}, true
+	case marshaled.Implements(textMarshalerType):
+		return PropertyDefinition{Type: typeOf("string")}, true
+	case ptr.Implements(jsonMarshalerType) || ptr.Implements(textMarshalerType):
+		ctx.warnOnce(t, "schematic: %s marshals itself only through a pointer receiver, which encoding/json doesn't call on values that aren't addressable; its schema is reflected from its kind", t)
+	}
+
+	return PropertyDefinition{}, false
+}
+
+// formatType returns the type of t in another format than JSON Schema, such as Avro.
+// Registered type mappings and self-describing types take precedence like in JSON
+// Schemas and are written as the primitive of their JSON type, a string when the
+// format has none. Well known types such as time.Time are then matched by their
+// printed name, like typeMapping. primitives holds the equivalents of the JSON types,
+// at least of "string".
+func formatType[T any](ctx *schemaContext, t reflect.Type, format string, primitives, wellKnown map[string]T) (T, bool) {
+	if custom, ok := ctx.customProperty(t); ok {
+		if primitive, ok := primitives[custom.Type.Primary()]; ok {
+			return primitive, true
+		}
+		ctx.warnOnce(t, "schematic: %s has no %s equivalent of its schema, it is written as a string", t, format)
+		return primitives["string"], true
+	}
+
+	typ, ok := wellKnown[t.String()]
+	return typ, ok
+}
+
+// warnOnce reports a warning through the Reflector's Warnf, once per type and generation
+func (ctx *schemaContext) warnOnce(t reflect.Type, format string, args ...interface{}) {
+	if ctx.reflector.Warnf == nil || ctx.warned[t] {
+		return
+	}
+	ctx.warned[t] = true
+	ctx.reflector.Warnf(format, args...)
+}
@@ -0,0 +1,224 @@
+package schematic
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Color marshals as a hex string although it is a struct
+type Color struct {
+	R, G, B uint8
+}
+
+func (c Color) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))
+}
+
+func (Color) JSONSchema() PropertyDefinition {
+	return PropertyDefinition{
+		Type:    SchemaType{"string"},
+		Pattern: "^#[0-9a-f]{6}$",
+	}
+}
+
+// Level describes itself through a pointer receiver
+type Level int
+
+func (*Level) JSONSchema() PropertyDefinition {
+	return PropertyDefinition{
+		Type:        SchemaType{"integer"},
+		Enum:        []interface{}{1, 2, 3},
+		Description: "Severity level",
+	}
+}
+
+type PaymentCard struct {
+	Number string `json:"number"`
+	Expiry string `json:"expiry"`
+	Holder string `json:"holder"`
+}
+
+type PaymentTransfer struct {
+	IBAN string `json:"iban"`
+}
+
+// PaymentMethod marshals one of its variants and composes their schemas
+type PaymentMethod struct {
+	Card     *PaymentCard
+	Transfer *PaymentTransfer
+}
+
+func (PaymentMethod) JSONSchemaReflect(r *Reflector) PropertyDefinition {
+	return PropertyDefinition{
+		AnyOf: []PropertyDefinition{
+			r.Property(PaymentCard{}),
+			r.Property(PaymentTransfer{}),
+		},
+	}
+}
+
+type Theme struct {
+	Primary  Color           `json:"primary"`
+	Palette  []Color         `json:"palette"`
+	Accent   *Color          `json:"accent"`
+	Level    Level           `json:"level"`
+	Payment  PaymentMethod   `json:"payment"`
+	Payments []PaymentMethod `json:"payments"`
+}
+
+func TestJSONSchemaHook(t *testing.T) {
+	schema := GenerateSchema(Theme{}, "Theme", "")
+	properties := schema.Properties
+
+	require.Equal(t, SchemaType{"string"}, properties["primary"].Type)
+	require.Equal(t, "^#[0-9a-f]{6}$", properties["primary"].Pattern)
+	require.Equal(t, "Primary", properties["primary"].Description)
+	require.Empty(t, properties["primary"].Properties)
+
+	require.Equal(t, SchemaType{"string"}, properties["palette"].Items.Type)
+	require.Equal(t, SchemaType{"string"}, properties["accent"].Type)
+
+	require.Equal(t, "Severity level", properties["level"].Description)
+	require.Equal(t, []interface{}{1, 2, 3}, properties["level"].Enum)
+}
+
+func TestJSONSchemaReflectHook(t *testing.T) {
+	schema := GenerateSchema(Theme{}, "Theme", "")
+
+	payment := schema.Properties["payment"]
+	require.Len(t, payment.AnyOf, 2)
+	// Sub-schemas share the definitions of the schema being generated
+	require.Equal(t, "#/$defs/PaymentCard", payment.AnyOf[0].Ref)
+	require.Contains(t, schema.Definitions, "PaymentCard")
+	require.Equal(t, SchemaType{"object"}, payment.AnyOf[1].Type)
+	require.Contains(t, payment.AnyOf[1].Properties, "iban")
+
+	require.Len(t, schema.Properties["payments"].Items.AnyOf, 2)
+
+	require.Empty(t, Validate(schema, []byte(`{
+		"primary": "#ffffff", "palette": [], "level": 1,
+		"payment": {"iban": "DE00"}, "payments": [{"number": "4111", "expiry": "12/30", "holder": "A"}]
+	}`)))
+}
+
+func TestReflectorProperty(t *testing.T) {
+	// Outside of a generation nested structs are inlined
+	property := NewReflector().Property(PaymentCard{})
+	require.Equal(t, SchemaType{"object"}, property.Type)
+	require.Len(t, property.Properties, 3)
+	require.Equal(t, []string{"number", "expiry", "holder"}, property.Required)
+}
+
+func TestReflectorPropertyRecursive(t *testing.T) {
+	var warnings []string
+	r := NewReflector(WithWarnings(func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}))
+
+	// A recursive struct refers to its definition, which is returned alongside it
+	property, definitions := r.PropertyWithDefinitions(RecursiveStruct{})
+	require.Equal(t, "#/$defs/RecursiveStruct", property.Ref)
+	require.Contains(t, definitions, "RecursiveStruct")
+	require.Equal(t, "#/$defs/RecursiveStruct", definitions["RecursiveStruct"].Properties["children"].Items.Ref)
+
+	// Property can only return the reference and says so
+	require.Equal(t, property, r.Property(RecursiveStruct{}))
+	require.Equal(t, []string{"schematic: schematic.RecursiveStruct is recursive, its schema refers to definitions only PropertyWithDefinitions returns"}, warnings)
+
+	_, definitions = r.PropertyWithDefinitions(PaymentCard{})
+	require.Nil(t, definitions)
+}
+
+// Weekday is an integer enum encoded as its name
+type Weekday int
+
+func (d Weekday) MarshalText() ([]byte, error) {
+	return []byte([]string{"sunday", "monday"}[d]), nil
+}
+
+type Marshalers struct {
+	IP       net.IP           `json:"ip"`
+	Big      *big.Int         `json:"big"`
+	Day      Weekday          `json:"day"`
+	Days     []Weekday        `json:"days"`
+	ByDay    map[Weekday]int  `json:"by_day"`
+	ByNumber map[int]int      `json:"by_number"`
+	When     time.Time        `json:"when"`
+	Raw      json.RawMessage  `json:"raw"`
+	Palette  map[string]Color `json:"palette"`
+}
+
+func TestMarshalerTypes(t *testing.T) {
+	var warnings []string
+	warnf := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	properties := GenerateProperties(Marshalers{}, WithWarnings(warnf))
+
+	require.Equal(t, SchemaType{"string"}, properties["ip"].Type)
+	require.Empty(t, properties["ip"].Items)
+
+	// big.Int implements json.Marshaler, its output isn't known
+	require.Empty(t, properties["big"].Type)
+	require.Empty(t, properties["big"].Properties)
+	require.Equal(t, []string{"schematic: big.Int implements json.Marshaler but not JSONSchemer, its schema is unconstrained"}, warnings)
+
+	require.Equal(t, SchemaType{"string"}, properties["day"].Type)
+	require.Equal(t, SchemaType{"string"}, properties["days"].Items.Type)
+
+	// Text keys are written as text, integer keys as numbers
+	require.Nil(t, properties["by_day"].PropertyNames)
+	require.NotNil(t, properties["by_number"].PropertyNames)
+
+	require.Equal(t, "date-time", properties["when"].Format)
+	require.Equal(t, SchemaType{"string"}, properties["raw"].Type)
+	require.Equal(t, "^#[0-9a-f]{6}$", properties["palette"].AdditionalProperties.Pattern)
+
+	// Warnings are opt-in
+	require.NotPanics(t, func() { GenerateProperties(Marshalers{}) })
+}
+
+// Priority marshals itself through a pointer receiver only
+type Priority int
+
+func (p *Priority) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("level-%d", int(*p))), nil
+}
+
+type PointerMarshalers struct {
+	Level    Priority         `json:"level"`
+	LevelPtr *Priority        `json:"level_ptr"`
+	Float    big.Float        `json:"float"`
+	ByLevel  map[Priority]int `json:"by_level"`
+}
+
+func TestPointerReceiverMarshalers(t *testing.T) {
+	var warnings []string
+	warnf := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+	schema := GenerateSchema(PointerMarshalers{}, "Pointer Marshalers", "", WithWarnings(warnf))
+
+	// encoding/json only calls the pointer methods through a pointer
+	require.Equal(t, SchemaType{"integer"}, schema.Properties["level"].Type)
+	require.Equal(t, SchemaType{"string"}, schema.Properties["level_ptr"].Type)
+	require.Equal(t, SchemaType{"object"}, schema.Properties["float"].Type)
+	require.NotNil(t, schema.Properties["by_level"].PropertyNames)
+	require.Equal(t, []string{
+		"schematic: schematic.Priority marshals itself only through a pointer receiver, which encoding/json doesn't call on values that aren't addressable; its schema is reflected from its kind",
+		"schematic: big.Float marshals itself only through a pointer receiver, which encoding/json doesn't call on values that aren't addressable; its schema is reflected from its kind",
+	}, warnings)
+
+	level := Priority(3)
+	payload, err := json.Marshal(PointerMarshalers{Level: 3, LevelPtr: &level, ByLevel: map[Priority]int{1: 2}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"level":3,"level_ptr":"level-3","float":{},"by_level":{"1":2}}`, string(payload))
+	require.Empty(t, Validate(schema, payload))
+}
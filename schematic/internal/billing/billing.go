@@ -0,0 +1,10 @@
+// Package billing holds types used by the schematic tests that share their
+// names with types of the schematic package
+package billing
+
+// Address is a billing address
+type Address struct {
+	Name    string `json:"name"`
+	Line1   string `json:"line1"`
+	Country string `json:"country"`
+}
@@ -0,0 +1,9 @@
+// Package billing is a second version of the billing package, whose types have
+// the same package name and type names as the first one
+package billing
+
+// Address is a billing address with a postal code
+type Address struct {
+	Name       string `json:"name"`
+	PostalCode string `json:"postal_code"`
+}
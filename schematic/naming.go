@@ -0,0 +1,69 @@
+package schematic
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy derives the JSON property name of a struct field that has no
+// name in its json tag from the Go field name. Any func(string) string can be
+// used as a custom strategy.
+type NamingStrategy func(fieldName string) string
+
+var (
+	// GoFieldNames keeps the Go field name unchanged, which is what encoding/json does
+	GoFieldNames NamingStrategy = func(fieldName string) string { return fieldName }
+	// SnakeCase converts field names to snake_case, e.g. UserID becomes user_i_d
+	SnakeCase NamingStrategy = toSnakeCase
+	// CamelCase converts field names to camelCase, e.g. UserID becomes userID
+	CamelCase NamingStrategy = toCamelCase
+	// KebabCase converts field names to kebab-case, e.g. UserID becomes user-i-d
+	KebabCase NamingStrategy = toKebabCase
+)
+
+// toSnakeCase converts PascalCase or camelCase to snake_case
+func toSnakeCase(s string) string {
+	return toDelimited(s, '_')
+}
+
+// toKebabCase converts PascalCase or camelCase to kebab-case
+func toKebabCase(s string) string {
+	return toDelimited(s, '-')
+}
+
+// toDelimited lower cases s and puts the delimiter in front of every upper case letter
+func toDelimited(s string, delimiter byte) string {
+	var result strings.Builder
+
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				result.WriteByte(delimiter)
+			}
+			result.WriteRune(unicode.ToLower(r))
+		} else {
+			result.WriteRune(r)
+		}
+	}
+
+	return result.String()
+}
+
+// toCamelCase converts PascalCase to camelCase by lower casing the leading
+// upper case letters, keeping the last one of an initialism that starts a
+// new word: XMLHttpRequest becomes xmlHttpRequest and ID becomes id
+func toCamelCase(s string) string {
+	runes := []rune(s)
+
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	return string(runes)
+}
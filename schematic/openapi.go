@@ -0,0 +1,165 @@
+package schematic
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification a document is written for
+type OpenAPIVersion string
+
+const (
+	// OpenAPI30 writes the schemas in the JSON Schema subset of OpenAPI 3.0, where
+	// null is allowed through the nullable keyword. Tuples are written as a single
+	// items schema and the propertyNames of maps are left out.
+	OpenAPI30 OpenAPIVersion = "3.0.3"
+	// OpenAPI31 writes the schemas as JSON Schema 2020-12, where null is a type
+	OpenAPI31 OpenAPIVersion = "3.1.0"
+)
+
+// componentsRefPrefix starts the references to the schemas of an OpenAPI document
+const componentsRefPrefix = "#/components/schemas/"
+
+// invalidComponentCharacters matches the characters the keys of OpenAPI components
+// can't hold, which must match ^[a-zA-Z0-9.\-_]+$, such as the slashes of full path
+// definition names
+var invalidComponentCharacters = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+var openAPIDialects = map[OpenAPIVersion]*dialect{
+	OpenAPI30: {refPrefix: componentsRefPrefix, singleItems: true, booleanExclusive: true, nullable: true, noPropertyNames: true},
+	OpenAPI31: {refPrefix: componentsRefPrefix},
+}
+
+// OpenAPIDocument is an OpenAPI document describing the schemas of Go types in its
+// components, to be merged into or referenced from the document of an API
+type OpenAPIDocument struct {
+	OpenAPI    OpenAPIVersion         `json:"openapi"`
+	Info       OpenAPIInfo            `json:"info"`
+	Paths      map[string]interface{} `json:"paths"`
+	Components OpenAPIComponents      `json:"components"`
+}
+
+// OpenAPIInfo is the metadata of an OpenAPI document
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIComponents holds the reusable objects of an OpenAPI document
+type OpenAPIComponents struct {
+	Schemas map[string]PropertyDefinition `json:"schemas"`
+}
+
+// GenerateOpenAPI creates an OpenAPI document holding the schemas of Go struct types
+// in components.schemas
+func GenerateOpenAPI(version OpenAPIVersion, info OpenAPIInfo, objects []interface{}, opts ...Option) (OpenAPIDocument, error) {
+	return NewReflector(opts...).OpenAPI(version, info, objects...)
+}
+
+// OpenAPI creates an OpenAPI document holding the schemas of Go struct values in
+// components.schemas. Each struct is named like a definition, the nested structs
+// placed in definitions by the DefinitionPolicy are added next to them.
+func (r *Reflector) OpenAPI(version OpenAPIVersion, info OpenAPIInfo, objects ...interface{}) (OpenAPIDocument, error) {
+	schemas, err := r.Components(objects...)
+	if err != nil {
+		return OpenAPIDocument{}, err
+	}
+
+	return OpenAPIDocument{
+		OpenAPI:    version,
+		Info:       info,
+		Paths:      map[string]interface{}{},
+		Components: OpenAPIComponents{Schemas: schemas},
+	}, nil
+}
+
+// Components creates the definitions of Go struct values, keyed by their names and
+// referring to each other with "#/$defs/..." references. The structs are always
+// part of the result, whatever the DefinitionPolicy. A value that isn't a struct,
+// or a pointer to one, has no component and is an error. The characters components
+// can't be named with, such as the slashes of FullPathDefinitionNames, are
+// replaced by underscores.
+func (r *Reflector) Components(objects ...interface{}) (map[string]PropertyDefinition, error) {
+	ctx := r.newContext()
+	ctx.roots = make(map[reflect.Type]bool, len(objects))
+
+	for _, object := range objects {
+		t := reflect.TypeOf(object)
+		root, ok := structOf(t)
+		if !ok || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			return nil, fmt.Errorf("components must be structs, got %s", t)
+		}
+		ctx.roots[root] = true
+		ctx.buildType(root, "")
+	}
+
+	return componentSchemas(ctx.newResolver(nil).definitions())
+}
+
+// componentSchemas renames the definitions holding characters components can't be
+// named with and rewrites the references to them. Definitions whose names only
+// differ by these characters can't be told apart and are an error.
+func componentSchemas(definitions map[string]PropertyDefinition) (map[string]PropertyDefinition, error) {
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	renamed := map[string]string{}
+	owners := make(map[string]string, len(names))
+	for _, name := range names {
+		component := invalidComponentCharacters.ReplaceAllString(name, "_")
+		if owner, taken := owners[component]; taken {
+			return nil, fmt.Errorf("definitions %s and %s have the same component name %s", owner, name, component)
+		}
+		owners[component] = name
+		if component != name {
+			renamed[name] = component
+		}
+	}
+	if len(renamed) == 0 {
+		return definitions, nil
+	}
+
+	rewrite := func(p PropertyDefinition) PropertyDefinition {
+		if !strings.HasPrefix(p.Ref, defsRefPrefix) {
+			return p
+		}
+		if component, ok := renamed[unescapePointer(strings.TrimPrefix(p.Ref, defsRefPrefix))]; ok {
+			p.Ref = defsRefPrefix + component
+		}
+		return p
+	}
+
+	schemas := make(map[string]PropertyDefinition, len(definitions))
+	for name, definition := range definitions {
+		if component, ok := renamed[name]; ok {
+			name = component
+		}
+		schemas[name] = definition.transform(rewrite)
+	}
+	return schemas, nil
+}
+
+// MarshalJSON writes the schemas with the keywords of the OpenAPI version, referring
+// to each other with "#/components/schemas/..." references
+func (doc OpenAPIDocument) MarshalJSON() ([]byte, error) {
+	type document OpenAPIDocument
+
+	d, ok := openAPIDialects[doc.OpenAPI]
+	if !ok {
+		d = openAPIDialects[OpenAPI31]
+		if strings.HasPrefix(string(doc.OpenAPI), "3.0") {
+			d = openAPIDialects[OpenAPI30]
+		}
+	}
+
+	out := document(doc)
+	out.Components.Schemas = d.properties(doc.Components.Schemas)
+	return json.Marshal(out)
+}
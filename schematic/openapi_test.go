@@ -0,0 +1,140 @@
+package schematic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type APICustomer struct {
+	Name  string  `json:"name"`
+	Email *string `json:"email"`
+}
+
+type APIOrder struct {
+	ID       string              `json:"id"`
+	Quantity int                 `json:"quantity" jsonschema:"exclusiveMinimum=0"`
+	Customer *APICustomer        `json:"customer"`
+	Size     [2]int              `json:"size"`
+	Ranks    map[int]APICustomer `json:"ranks"`
+}
+
+func TestOpenAPIComponents(t *testing.T) {
+	schemas, err := NewReflector().Components(APIOrder{}, APICustomer{})
+	require.NoError(t, err)
+
+	// Both structs are components although the customer is below the definition threshold
+	require.Len(t, schemas, 2)
+	require.Equal(t, "#/$defs/APICustomer", schemas["APIOrder"].Properties["customer"].Ref)
+	require.Equal(t, []string{"id", "quantity", "size", "ranks"}, schemas["APIOrder"].Required)
+
+	_, err = NewReflector().Components("not a struct")
+	require.EqualError(t, err, "components must be structs, got string")
+}
+
+func TestOpenAPIComponentNames(t *testing.T) {
+	schemas, err := NewReflector(WithDefinitionNaming(FullPathDefinitionNames)).Components(APIOrder{}, APICustomer{})
+	require.NoError(t, err)
+
+	// The slashes of the package paths aren't allowed in component names
+	customer := "github.com_sadrishehu_schematic_schematic.APICustomer"
+	require.Contains(t, schemas, customer)
+	require.Contains(t, schemas, "github.com_sadrishehu_schematic_schematic.APIOrder")
+	for name := range schemas {
+		require.Regexp(t, `^[a-zA-Z0-9.\-_]+$`, name)
+	}
+	order := schemas["github.com_sadrishehu_schematic_schematic.APIOrder"]
+	require.Equal(t, "#/$defs/"+customer, order.Properties["customer"].Ref)
+	require.Equal(t, "#/$defs/"+customer, order.Properties["ranks"].AdditionalProperties.Ref)
+
+	_, err = componentSchemas(map[string]PropertyDefinition{"billing/Address": {}, "billing_Address": {}})
+	require.EqualError(t, err, "definitions billing/Address and billing_Address have the same component name billing_Address")
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	info := OpenAPIInfo{Title: "Orders", Version: "1.0.0"}
+
+	tests := []struct {
+		version  OpenAPIVersion
+		expected string
+	}{
+		{OpenAPI30, `{
+			"openapi": "3.0.3", "info": {"title": "Orders", "version": "1.0.0"}, "paths": {},
+			"components": {"schemas": {
+				"APIOrder": {"type": "object", "required": ["id", "quantity", "size", "ranks"], "properties": {
+					"id": {"type": "string", "description": "ID"},
+					"quantity": {"type": "integer", "description": "Quantity", "minimum": 0, "exclusiveMinimum": true},
+					"customer": {"allOf": [{"$ref": "#/components/schemas/APICustomer"}], "nullable": true, "description": "Customer"},
+					"size": {"type": "array", "description": "Size", "items": {"type": "integer", "description": "Size"}, "minItems": 2, "maxItems": 2},
+					"ranks": {"type": "object", "description": "Ranks", "additionalProperties": {"$ref": "#/components/schemas/APICustomer", "description": "Ranks"}}
+				}},
+				"APICustomer": {"type": "object", "description": "Customer", "required": ["name"], "properties": {
+					"name": {"type": "string", "description": "Name"},
+					"email": {"type": "string", "nullable": true, "description": "Email"}
+				}}
+			}}
+		}`},
+		{OpenAPI31, `{
+			"openapi": "3.1.0", "info": {"title": "Orders", "version": "1.0.0"}, "paths": {},
+			"components": {"schemas": {
+				"APIOrder": {"type": "object", "required": ["id", "quantity", "size", "ranks"], "properties": {
+					"id": {"type": "string", "description": "ID"},
+					"quantity": {"type": "integer", "description": "Quantity", "exclusiveMinimum": 0},
+					"customer": {"anyOf": [{"$ref": "#/components/schemas/APICustomer"}, {"type": "null"}], "description": "Customer"},
+					"size": {"type": "array", "description": "Size", "items": {"type": "integer", "description": "Size"}, "minItems": 2, "maxItems": 2},
+					"ranks": {"type": "object", "description": "Ranks", "propertyNames": {"type": "string", "pattern": "^-?[0-9]+$"},
+						"additionalProperties": {"$ref": "#/components/schemas/APICustomer", "description": "Ranks"}}
+				}},
+				"APICustomer": {"type": "object", "description": "Customer", "required": ["name"], "properties": {
+					"name": {"type": "string", "description": "Name"},
+					"email": {"type": ["string", "null"], "description": "Email"}
+				}}
+			}}
+		}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.version), func(t *testing.T) {
+			doc, err := GenerateOpenAPI(tt.version, info, []interface{}{APIOrder{}}, WithNullablePointers(), WithDefinitionPolicy(NamedDefinitions))
+			require.NoError(t, err)
+
+			marshaled, err := json.Marshal(doc)
+			require.NoError(t, err)
+			require.JSONEq(t, tt.expected, string(marshaled))
+		})
+	}
+}
+
+type APIPoint struct {
+	X, Y float64
+}
+
+type APIRoute struct {
+	Start APIPoint   `json:"start"`
+	Stops []APIPoint `json:"stops"`
+}
+
+func TestOpenAPI30Tuples(t *testing.T) {
+	// A point is encoded as a [latitude, longitude, label] tuple
+	tuple := PropertyDefinition{Type: typeOf(typeArray), PrefixItems: []PropertyDefinition{
+		{Type: typeOf("number")}, {Type: typeOf("number")}, {Type: typeOf("string")},
+	}}
+	r := NewReflector(WithTypeMapping(reflect.TypeOf(APIPoint{}), tuple))
+
+	doc, err := r.OpenAPI(OpenAPI30, OpenAPIInfo{Title: "Routes", Version: "1.0.0"}, APIRoute{})
+	require.NoError(t, err)
+	marshaled, err := json.Marshal(doc)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"openapi": "3.0.3", "info": {"title": "Routes", "version": "1.0.0"}, "paths": {},
+		"components": {"schemas": {
+			"APIRoute": {"type": "object", "required": ["start"], "properties": {
+				"start": {"type": "array", "description": "Start", "items": {"anyOf": [{"type": "number"}, {"type": "string"}]}, "maxItems": 3},
+				"stops": {"type": "array", "description": "Stops", "items": {"type": "array", "description": "Stops",
+					"items": {"anyOf": [{"type": "number"}, {"type": "string"}]}, "maxItems": 3}}
+			}}
+		}}
+	}`, string(marshaled))
+}
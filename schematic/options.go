@@ -0,0 +1,97 @@
+package schematic
+
+import (
+	"reflect"
+)
+
+// Option configures a Reflector
+type Option func(*Reflector)
+
+// WithNamingStrategy sets how fields without a json tag are named. The default,
+// GoFieldNames, matches encoding/json.
+func WithNamingStrategy(naming NamingStrategy) Option {
+	return func(r *Reflector) {
+		if naming != nil {
+			r.NamingStrategy = naming
+		}
+	}
+}
+
+// WithDefinitionThreshold sets the number of properties above which a nested struct is
+// placed in $defs and referenced instead of being inlined. The default is 2.
+func WithDefinitionThreshold(threshold int) Option {
+	return func(r *Reflector) {
+		r.DefinitionThreshold = threshold
+	}
+}
+
+// WithDefinitionPolicy sets which structs are placed in $defs, e.g. ReusedDefinitions to
+// only reference the structs used more than once and inline the others
+func WithDefinitionPolicy(policy DefinitionPolicy) Option {
+	return func(r *Reflector) {
+		r.DefinitionPolicy = policy
+	}
+}
+
+// WithNullablePointers makes pointer fields accept null, which is what encoding/json
+// writes for nil pointers. Typed properties become a type union such as
+// `["string","null"]` and references become `anyOf` a `$ref` and `{"type":"null"}`.
+func WithNullablePointers() Option {
+	return func(r *Reflector) {
+		r.NullablePointers = true
+	}
+}
+
+// WithTypeMapping describes values of type t with def instead of reflecting the type
+func WithTypeMapping(t reflect.Type, def PropertyDefinition) Option {
+	return func(r *Reflector) {
+		r.RegisterType(t, def)
+	}
+}
+
+// WithTypeMapper describes values of type t with the schema returned by fn
+func WithTypeMapper(t reflect.Type, fn TypeMapper) Option {
+	return func(r *Reflector) {
+		r.RegisterTypeFunc(t, fn)
+	}
+}
+
+// WithDefinitionNaming sets how the $defs entries of named types are named, e.g.
+// PackageDefinitionNames to qualify every definition by its package
+func WithDefinitionNaming(naming DefinitionNaming) Option {
+	return func(r *Reflector) {
+		r.DefinitionNaming = naming
+	}
+}
+
+// WithWarnings reports warnings about types whose schema can't be determined
+// precisely, such as json.Marshaler implementations without a JSONSchema hook.
+// log.Printf can be passed directly.
+func WithWarnings(warnf func(format string, args ...interface{})) Option {
+	return func(r *Reflector) {
+		r.Warnf = warnf
+	}
+}
+
+// WithDraft selects the JSON Schema draft the schemas are written for. It sets the
+// $schema keyword, which decides how the schemas are marshaled.
+func WithDraft(draft Draft) Option {
+	return WithSchemaURL(string(draft))
+}
+
+// WithIDBaseURI sets the base URI of the $id BuildEvents gives the generated schemas,
+// e.g. "https://schemas.acme.com/events/" for "https://schemas.acme.com/events/order_placed.json"
+// when the event "order.placed" is written to order_placed.json.
+func WithIDBaseURI(baseURI string) Option {
+	return func(r *Reflector) {
+		r.IDBaseURI = baseURI
+	}
+}
+
+// WithSchemaURL sets the value of the $schema keyword. A URL of a known draft
+// selects the keywords of that draft, see WithDraft.
+func WithSchemaURL(schemaURL string) Option {
+	return func(r *Reflector) {
+		r.SchemaURL = schemaURL
+	}
+}
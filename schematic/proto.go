@@ -0,0 +1,671 @@
+package schematic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ProtoFile is a proto3 file describing an event and the messages it uses. Its
+// String method returns the .proto source.
+type ProtoFile struct {
+	Package  string
+	Imports  []string
+	Messages []*ProtoMessage
+}
+
+// ProtoMessage is a message of a ProtoFile. Nested holds the messages of anonymous
+// structs and the wrappers of nested collections, which protobuf can't express directly.
+type ProtoMessage struct {
+	Name     string
+	Fields   []ProtoField
+	Reserved []int
+	Nested   []*ProtoMessage
+
+	// key identifies the message in a ProtoLock: the full path of its Go type, or
+	// the key of its parent and its name for nested messages
+	key string
+}
+
+// ProtoField is a field of a ProtoMessage
+type ProtoField struct {
+	// Label is "optional", "repeated" or empty
+	Label  string
+	Type   string
+	Name   string
+	Number int
+	// JSONName is set when the JSON name of the field isn't a valid field name
+	JSONName string
+}
+
+// ProtoLock records the field numbers assigned to every message, so that they stay
+// the same when fields are added, reordered or removed. The numbers of removed
+// fields are reserved and never reused.
+type ProtoLock struct {
+	// Messages holds the field numbers keyed by the Go type of the message, e.g.
+	// "github.com/acme/events.Address", and the field name. Nested messages are keyed
+	// by the key of their parent and their name, e.g. "github.com/acme/events.Order.Extra".
+	Messages map[string]map[string]int `json:"messages"`
+}
+
+const (
+	// maxProtoFieldNumber is the largest field number protobuf accepts
+	maxProtoFieldNumber = 536870911
+	// The field numbers between reservedProtoFieldStart and reservedProtoFieldEnd
+	// are reserved for the protobuf implementation
+	reservedProtoFieldStart = 19000
+	reservedProtoFieldEnd   = 19999
+)
+
+const (
+	protoTimestampImport = "google/protobuf/timestamp.proto"
+	protoStructImport    = "google/protobuf/struct.proto"
+)
+
+// protoTypes maps well known types and scalar kinds to protobuf types
+var protoTypes = map[string]string{
+	"time.Time":       "google.protobuf.Timestamp",
+	"uuid.UUID":       "string",
+	"json.RawMessage": "string",
+	"string":          "string",
+	"bool":            "bool",
+	"float32":         "float",
+	"float64":         "double",
+	"int8":            "int32",
+	"int16":           "int32",
+	"int32":           "int32",
+	"int":             "int64",
+	"int64":           "int64",
+	"uint8":           "uint32",
+	"uint16":          "uint32",
+	"uint32":          "uint32",
+	"uint":            "uint64",
+	"uint64":          "uint64",
+}
+
+// protoPrimitives maps the JSON Schema types of custom type mappings to protobuf types
+var protoPrimitives = map[string]string{
+	"string":  "string",
+	"integer": "int64",
+	"number":  "double",
+	"boolean": "bool",
+}
+
+// invalidProtoName matches the characters not allowed in protobuf identifiers
+var invalidProtoName = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// protoContext tracks state while a proto file is generated
+type protoContext struct {
+	schema *schemaContext
+	lock   *ProtoLock
+	file   *ProtoFile
+	// messages holds the names of the messages of named structs and names the
+	// structs they were given to
+	messages map[reflect.Type]string
+	names    map[string]reflect.Type
+	// err is the first invalid proto tag found
+	err error
+}
+
+// LoadProtoLock reads a lock file written by ProtoLock.Save. A missing file gives an empty lock.
+func LoadProtoLock(filename string) (*ProtoLock, error) {
+	lock := &ProtoLock{Messages: map[string]map[string]int{}}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while reading proto lock %s: %w", filename, err)
+	}
+
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("error while parsing proto lock %s: %w", filename, err)
+	}
+	if lock.Messages == nil {
+		lock.Messages = map[string]map[string]int{}
+	}
+	return lock, nil
+}
+
+// Save writes the lock file
+func (l *ProtoLock) Save(filename string) error {
+	marshal, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while marshaling proto lock: %w", err)
+	}
+	if err := os.WriteFile(filename, marshal, 0o644); err != nil {
+		return fmt.Errorf("error while writing file %s: %w", filename, err)
+	}
+	return nil
+}
+
+// GenerateProto creates a proto3 file from a Go struct type. The field numbers are
+// taken from `proto:"3"` tags, then from the lock, which is updated with the numbers
+// of new fields. The lock may be nil.
+func GenerateProto[T any](object T, pkg string, lock *ProtoLock, opts ...Option) (ProtoFile, error) {
+	return NewReflector(opts...).Proto(object, pkg, lock)
+}
+
+// Proto creates a proto3 file from a Go struct value. Fields are named like the JSON
+// properties; pointers are optional, slices and arrays repeated, maps map<,> fields,
+// named structs messages of the file and anonymous structs nested messages.
+// time.Time becomes google.protobuf.Timestamp.
+//
+// Field numbers come from `proto:"3"` tags, then from the lock. The remaining fields
+// take the lowest numbers left free, or the numbers after the highest one the lock
+// holds for the message, and are recorded in the lock, which may be nil. A proto
+// tag that isn't a valid field number or repeats the number of another field is an
+// error, which leaves the lock unchanged.
+func (r *Reflector) Proto(object interface{}, pkg string, lock *ProtoLock) (ProtoFile, error) {
+	t, ok := structOf(reflect.TypeOf(object))
+	if !ok {
+		return ProtoFile{}, fmt.Errorf("protobuf messages must be structs, got %T", object)
+	}
+	if lock == nil {
+		lock = &ProtoLock{}
+	}
+
+	// The numbers are assigned in a copy of the lock, kept only on success
+	working := &ProtoLock{Messages: make(map[string]map[string]int, len(lock.Messages))}
+	for key, fields := range lock.Messages {
+		working.Messages[key] = make(map[string]int, len(fields))
+		for name, number := range fields {
+			working.Messages[key][name] = number
+		}
+	}
+
+	ctx := &protoContext{
+		schema:   r.newContext(),
+		lock:     working,
+		file:     &ProtoFile{Package: pkg},
+		messages: map[reflect.Type]string{},
+		names:    map[string]reflect.Type{},
+	}
+	ctx.namedMessage(t)
+	if ctx.err != nil {
+		return ProtoFile{}, ctx.err
+	}
+	lock.Messages = working.Messages
+	sort.Strings(ctx.file.Imports)
+
+	return *ctx.file, nil
+}
+
+// namedMessage returns the name of the message of a named struct, adding the message
+// to the file on first use. Structs sharing a name are qualified by their package.
+func (ctx *protoContext) namedMessage(t reflect.Type) string {
+	if name, ok := ctx.messages[t]; ok {
+		return name
+	}
+
+	name := protoMessageName(shortTypeName(t))
+	if _, taken := ctx.names[name]; taken && t.PkgPath() != "" {
+		name = protoMessageName(packageName(t.PkgPath())) + name
+	}
+	base := name
+	for suffix := 2; ctx.names[name] != nil; suffix++ {
+		name = base + strconv.Itoa(suffix)
+	}
+	ctx.messages[t] = name
+	ctx.names[name] = t
+
+	// The lock is keyed by the type rather than the name, which changes when the
+	// structs sharing a name are used in another order
+	key := fullPathTypeName(t)
+	if t.Name() == "" {
+		key = name
+	}
+	message := &ProtoMessage{Name: name, key: key}
+	ctx.file.Messages = append(ctx.file.Messages, message)
+	ctx.fillMessage(message, t)
+
+	return name
+}
+
+// fillMessage adds the fields of a struct to its message
+func (ctx *protoContext) fillMessage(message *ProtoMessage, t reflect.Type) {
+	fields := structFields(t, ctx.schema.reflector.naming())
+	numbers, err := ctx.fieldNumbers(message.key, fields)
+	if err != nil {
+		if ctx.err == nil {
+			ctx.err = fmt.Errorf("message %s: %w", message.Name, err)
+		}
+		return
+	}
+
+	for _, field := range fields {
+		name := protoFieldName(field.name)
+		label, typ := ctx.fieldType(message, field.Type, field.Name)
+
+		protoField := ProtoField{Label: label, Type: typ, Name: name, Number: numbers[field.name]}
+		if name != field.name {
+			protoField.JSONName = field.name
+		}
+		message.Fields = append(message.Fields, protoField)
+	}
+
+	used := make(map[int]bool, len(numbers))
+	for _, number := range numbers {
+		used[number] = true
+	}
+
+	// Numbers of removed fields stay in the lock and are reserved, unless a tag reuses them
+	locked := ctx.lock.Messages[message.key]
+	for fieldName, number := range locked {
+		if _, ok := numbers[fieldName]; ok {
+			continue
+		}
+		if used[number] {
+			delete(locked, fieldName)
+			continue
+		}
+		message.Reserved = append(message.Reserved, number)
+	}
+	sort.Ints(message.Reserved)
+
+	if locked == nil {
+		locked = map[string]int{}
+		ctx.lock.Messages[message.key] = locked
+	}
+	for fieldName, number := range numbers {
+		locked[fieldName] = number
+	}
+}
+
+// fieldNumbers assigns a number to every field of a message, keyed by the JSON name.
+// The fields with an invalid proto tag are numbered like untagged fields.
+func (ctx *protoContext) fieldNumbers(key string, fields []structField) (map[string]int, error) {
+	numbers := make(map[string]int, len(fields))
+	used := map[int]string{}
+	var err error
+
+	for _, field := range fields {
+		tag, ok := field.Tag.Lookup("proto")
+		if !ok {
+			continue
+		}
+		number, convErr := strconv.Atoi(tag)
+		if convErr != nil || !validProtoFieldNumber(number) {
+			if err == nil {
+				err = fmt.Errorf("invalid proto tag on field %s: %q is not a valid field number", field.Name, tag)
+			}
+			continue
+		}
+		if other, ok := used[number]; ok {
+			if err == nil {
+				err = fmt.Errorf("invalid proto tag on field %s: number %d is used by %s too", field.Name, number, other)
+			}
+			continue
+		}
+		numbers[field.name] = number
+		used[number] = field.Name
+	}
+
+	// Fields of the lock keep their number unless a tag took it
+	locked := ctx.lock.Messages[key]
+	for _, field := range fields {
+		if _, ok := numbers[field.name]; ok {
+			continue
+		}
+		if number, ok := locked[field.name]; ok {
+			if _, taken := used[number]; !taken {
+				numbers[field.name] = number
+				used[number] = field.Name
+			}
+		}
+	}
+
+	// The other fields take the free numbers from 1, or after the highest number of
+	// the lock for a message generated before so that numbers of removed fields
+	// aren't reused
+	next := 1
+	for _, number := range locked {
+		if number >= next {
+			next = number + 1
+		}
+	}
+	for _, field := range fields {
+		if _, ok := numbers[field.name]; ok {
+			continue
+		}
+		for !freeProtoFieldNumber(next, used) {
+			next++
+		}
+		numbers[field.name] = next
+		used[next] = field.Name
+	}
+
+	return numbers, err
+}
+
+// freeProtoFieldNumber reports whether a valid number isn't used by another field
+func freeProtoFieldNumber(number int, used map[int]string) bool {
+	_, taken := used[number]
+	return !taken && validProtoFieldNumber(number)
+}
+
+// validProtoFieldNumber reports whether a number can be used as a field number
+func validProtoFieldNumber(number int) bool {
+	return number > 0 && number <= maxProtoFieldNumber &&
+		(number < reservedProtoFieldStart || number > reservedProtoFieldEnd)
+}
+
+// fieldType returns the label and the type of a field of Go type t. Anonymous
+// structs and the wrappers of nested collections are added to message, named
+// after the Go field.
+func (ctx *protoContext) fieldType(message *ProtoMessage, t reflect.Type, name string) (string, string) {
+	if t.Kind() == reflect.Ptr {
+		label, typ := ctx.fieldType(message, t.Elem(), name)
+		if label == "" && !strings.HasPrefix(typ, "map<") {
+			label = "optional"
+		}
+		return label, typ
+	}
+
+	if typ, ok := formatType(ctx.schema, t, "protobuf", protoPrimitives, protoTypes); ok {
+		if typ == "google.protobuf.Timestamp" {
+			ctx.addImport(protoTimestampImport)
+		}
+		return "", typ
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return "", "bytes"
+		}
+		label, typ := ctx.fieldType(message, t.Elem(), name)
+		if label == "repeated" || strings.HasPrefix(typ, "map<") {
+			typ = ctx.wrapper(message, name, label, typ)
+		}
+		return "repeated", typ
+	case reflect.Map:
+		label, typ := ctx.fieldType(message, t.Elem(), name)
+		if label == "repeated" || strings.HasPrefix(typ, "map<") {
+			typ = ctx.wrapper(message, name, label, typ)
+		}
+		return "", "map<" + protoMapKey(t.Key()) + ", " + typ + ">"
+	case reflect.Struct:
+		if t.Name() != "" {
+			return "", ctx.namedMessage(t)
+		}
+		nested := &ProtoMessage{Name: protoMessageName(name)}
+		nested.key = message.key + "." + nested.Name
+		message.Nested = append(message.Nested, nested)
+		ctx.fillMessage(nested, t)
+		return "", nested.Name
+	case reflect.Interface:
+		ctx.addImport(protoStructImport)
+		return "", "google.protobuf.Value"
+	}
+
+	if typ, ok := protoTypes[t.Kind().String()]; ok {
+		return "", typ
+	}
+	return "", "string"
+}
+
+// wrapper adds a nested message holding a collection that can't be the element of
+// another collection, e.g. the inner slices of a [][]string. Wrappers are named after
+// the field with one Item suffix per level, from the innermost collection out:
+// a [][][]string field grid uses GridItem for []string and GridItemItem for [][]string.
+func (ctx *protoContext) wrapper(message *ProtoMessage, name, label, typ string) string {
+	wrapperName := protoMessageName(name) + "Item"
+	for message.nested(wrapperName) {
+		wrapperName += "Item"
+	}
+
+	wrapper := &ProtoMessage{
+		Name:   wrapperName,
+		Fields: []ProtoField{{Label: label, Type: typ, Name: "value", Number: 1}},
+	}
+	message.Nested = append(message.Nested, wrapper)
+	return wrapper.Name
+}
+
+// nested reports whether the message has a nested message with the name
+func (m *ProtoMessage) nested(name string) bool {
+	for _, nested := range m.Nested {
+		if nested.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addImport imports a proto file once
+func (ctx *protoContext) addImport(path string) {
+	for _, imported := range ctx.file.Imports {
+		if imported == path {
+			return
+		}
+	}
+	ctx.file.Imports = append(ctx.file.Imports, path)
+}
+
+// protoMapKey returns the type of map keys. Keys that protobuf doesn't allow are
+// written as strings, which is how encoding/json writes them.
+func protoMapKey(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return protoTypes[t.Kind().String()]
+	}
+	return "string"
+}
+
+// protoFieldName turns a JSON name into a protobuf field name
+func protoFieldName(name string) string {
+	name = invalidProtoName.ReplaceAllString(name, "_")
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
+
+// protoMessageName turns a type or field name into a protobuf message name
+func protoMessageName(name string) string {
+	name = protoFieldName(name)
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// String returns the .proto source of the file
+func (f ProtoFile) String() string {
+	var b strings.Builder
+
+	b.WriteString("syntax = \"proto3\";\n")
+	if f.Package != "" {
+		fmt.Fprintf(&b, "\npackage %s;\n", f.Package)
+	}
+	if len(f.Imports) > 0 {
+		b.WriteString("\n")
+		for _, path := range f.Imports {
+			fmt.Fprintf(&b, "import %q;\n", path)
+		}
+	}
+	for _, message := range f.Messages {
+		b.WriteString("\n")
+		message.write(&b, "")
+	}
+
+	return b.String()
+}
+
+// String returns the .proto source of the message
+func (m *ProtoMessage) String() string {
+	var b strings.Builder
+	m.write(&b, "")
+	return b.String()
+}
+
+// write writes the message source with the given indentation
+func (m *ProtoMessage) write(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%smessage %s {\n", indent, m.Name)
+
+	if len(m.Reserved) > 0 {
+		reserved := make([]string, len(m.Reserved))
+		for i, number := range m.Reserved {
+			reserved[i] = strconv.Itoa(number)
+		}
+		fmt.Fprintf(b, "%s  reserved %s;\n", indent, strings.Join(reserved, ", "))
+	}
+
+	for _, field := range m.Fields {
+		b.WriteString(indent + "  ")
+		if field.Label != "" {
+			b.WriteString(field.Label + " ")
+		}
+		fmt.Fprintf(b, "%s %s = %d", field.Type, field.Name, field.Number)
+		if field.JSONName != "" {
+			fmt.Fprintf(b, " [json_name = %q]", field.JSONName)
+		}
+		b.WriteString(";\n")
+	}
+
+	for _, nested := range m.Nested {
+		b.WriteString("\n")
+		nested.write(b, indent+"  ")
+	}
+
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// BuildProto writes the proto files of events to .proto files named like the JSON
+// Schema files written by BuildEvents. Files of the same package can't declare the
+// same message, so a message that several of them declare, e.g. the event tags, is
+// an error unless WithCommonDefinitions is given: the message is then written once
+// to a common file of the package, e.g. common.proto, imported by the event files.
+func BuildProto(path *string, genProto map[string]ProtoFile, opts ...BuildOption) error {
+	config := &buildConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	protoFiles, err := config.protoFiles(genProto)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string][]byte, len(protoFiles))
+	for filename, file := range protoFiles {
+		files[filename] = []byte(file.String())
+	}
+
+	return writeFiles(path, files)
+}
+
+// protoFiles returns the proto files to write keyed by their file name, with the
+// messages declared by several files of a package moved to the common file
+func (c *buildConfig) protoFiles(genProto map[string]ProtoFile) (map[string]ProtoFile, error) {
+	files := make(map[string]ProtoFile, len(genProto)+1)
+	filenames := make([]string, 0, len(genProto))
+	for name, file := range genProto {
+		filename := eventFileName(name, ".proto")
+		files[filename] = file
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	// Messages are identified by their package and name, in the order of the files
+	var order []string
+	declared := map[string][]string{}
+	messages := map[string]*ProtoMessage{}
+	for _, filename := range filenames {
+		for _, message := range files[filename].Messages {
+			fullName := strings.TrimPrefix(files[filename].Package+"."+message.Name, ".")
+			if first, ok := messages[fullName]; !ok {
+				messages[fullName] = message
+				order = append(order, fullName)
+			} else if first.String() != message.String() {
+				return nil, fmt.Errorf("message %s differs between %s and %s", fullName, declared[fullName][0], filename)
+			}
+			declared[fullName] = append(declared[fullName], filename)
+		}
+	}
+
+	var shared []string
+	for _, fullName := range order {
+		if len(declared[fullName]) > 1 {
+			shared = append(shared, fullName)
+		}
+	}
+	if len(shared) == 0 {
+		return files, nil
+	}
+	if c.common == "" {
+		owners := declared[shared[0]]
+		return nil, fmt.Errorf("message %s is declared by %s and %s, write it once with WithCommonDefinitions", shared[0], owners[0], owners[1])
+	}
+
+	commonFile := eventFileName(c.common, ".proto")
+	if _, exists := files[commonFile]; exists {
+		return nil, fmt.Errorf("common proto file %s has the name of an event", commonFile)
+	}
+
+	common := ProtoFile{Package: files[declared[shared[0]][0]].Package}
+	moved := map[string]bool{}
+	for _, fullName := range shared {
+		if pkg := files[declared[fullName][0]].Package; pkg != common.Package {
+			return nil, fmt.Errorf("common proto file %s can't be shared by the packages %s and %s", commonFile, common.Package, pkg)
+		}
+		common.Messages = append(common.Messages, messages[fullName])
+		moved[fullName] = true
+	}
+	common.Imports = protoImports(common.Messages)
+	files[commonFile] = common
+
+	for _, filename := range filenames {
+		file := files[filename]
+		var kept []*ProtoMessage
+		for _, message := range file.Messages {
+			if !moved[strings.TrimPrefix(file.Package+"."+message.Name, ".")] {
+				kept = append(kept, message)
+			}
+		}
+		if len(kept) == len(file.Messages) {
+			continue
+		}
+
+		file.Messages = kept
+		file.Imports = append(protoImports(kept), commonFile)
+		sort.Strings(file.Imports)
+		files[filename] = file
+	}
+
+	return files, nil
+}
+
+// protoImports returns the well known types the fields of messages use, sorted
+func protoImports(messages []*ProtoMessage) []string {
+	used := map[string]bool{}
+	var visit func(messages []*ProtoMessage)
+	visit = func(messages []*ProtoMessage) {
+		for _, message := range messages {
+			for _, field := range message.Fields {
+				switch {
+				case strings.Contains(field.Type, "google.protobuf.Timestamp"):
+					used[protoTimestampImport] = true
+				case strings.Contains(field.Type, "google.protobuf.Value"):
+					used[protoStructImport] = true
+				}
+			}
+			visit(message.Nested)
+		}
+	}
+	visit(messages)
+
+	imports := make([]string, 0, len(used))
+	for path := range used {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	return imports
+}
@@ -0,0 +1,309 @@
+package schematic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sadrishehu/schematic/schematic/internal/billing"
+	"github.com/stretchr/testify/require"
+)
+
+type ProtoTags struct {
+	EventName string `json:"event_name"`
+	EventID   string `json:"event-id"`
+}
+
+type ProtoEvent struct {
+	Tags      ProtoTags            `json:"tags"`
+	Note      *string              `json:"note"`
+	Count     int32                `json:"count" proto:"7"`
+	CreatedAt time.Time            `json:"created_at"`
+	Labels    []string             `json:"labels"`
+	Matrix    [][]float64          `json:"matrix"`
+	Scores    map[string]int       `json:"scores"`
+	Extra     struct{ Key string } `json:"extra"`
+	Payload   interface{}          `json:"payload"`
+}
+
+func TestProto(t *testing.T) {
+	file, err := GenerateProto(ProtoEvent{}, "acme.events", nil)
+	require.NoError(t, err)
+
+	require.Equal(t, `syntax = "proto3";
+
+package acme.events;
+
+import "google/protobuf/struct.proto";
+import "google/protobuf/timestamp.proto";
+
+message ProtoEvent {
+  ProtoTags tags = 1;
+  optional string note = 2;
+  int32 count = 7;
+  google.protobuf.Timestamp created_at = 3;
+  repeated string labels = 4;
+  repeated MatrixItem matrix = 5;
+  map<string, int64> scores = 6;
+  Extra extra = 8;
+  google.protobuf.Value payload = 9;
+
+  message MatrixItem {
+    repeated double value = 1;
+  }
+
+  message Extra {
+    string Key = 1;
+  }
+}
+
+message ProtoTags {
+  string event_name = 1;
+  string event_id = 2 [json_name = "event-id"];
+}
+`, file.String())
+}
+
+type ProtoOrderV1 struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+type ProtoOrderV2 struct {
+	Total  float64 `json:"total"`
+	Status string  `json:"status"`
+	ID     string  `json:"id"`
+}
+
+func TestProtoLock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "proto.lock")
+
+	lock, err := LoadProtoLock(filename)
+	require.NoError(t, err)
+	v1, err := NewReflector().Proto(ProtoOrderV1{}, "", lock)
+	require.NoError(t, err)
+	require.Equal(t, []ProtoField{
+		{Type: "string", Name: "id", Number: 1},
+		{Type: "string", Name: "status", Number: 2},
+		{Type: "string", Name: "note", Number: 3},
+	}, v1.Messages[0].Fields)
+	require.NoError(t, lock.Save(filename))
+
+	// Once its lock entry is moved, the renamed type keeps the numbers of its fields
+	lock, err = LoadProtoLock(filename)
+	require.NoError(t, err)
+	lock.Messages["github.com/sadrishehu/schematic/schematic.ProtoOrderV2"] = lock.Messages["github.com/sadrishehu/schematic/schematic.ProtoOrderV1"]
+	v2, err := NewReflector().Proto(ProtoOrderV2{}, "", lock)
+	require.NoError(t, err)
+	require.Equal(t, []ProtoField{
+		{Type: "double", Name: "total", Number: 4},
+		{Type: "string", Name: "status", Number: 2},
+		{Type: "string", Name: "id", Number: 1},
+	}, v2.Messages[0].Fields)
+	require.Equal(t, []int{3}, v2.Messages[0].Reserved)
+	require.Equal(t, map[string]int{"id": 1, "status": 2, "note": 3, "total": 4}, lock.Messages["github.com/sadrishehu/schematic/schematic.ProtoOrderV2"])
+}
+
+type ProtoAddresses struct {
+	Shipping Address         `json:"shipping"`
+	Billing  billing.Address `json:"billing"`
+}
+
+type ProtoAddressesSwapped struct {
+	Billing  billing.Address `json:"billing"`
+	Shipping Address         `json:"shipping"`
+}
+
+func TestProtoLockKeys(t *testing.T) {
+	lock := &ProtoLock{}
+	file, err := GenerateProto(ProtoAddresses{}, "", lock)
+	require.NoError(t, err)
+	require.Equal(t, "BillingAddress", file.Messages[2].Name)
+	require.Equal(t, map[string]int{"name": 1, "line1": 2, "country": 3},
+		lock.Messages["github.com/sadrishehu/schematic/schematic/internal/billing.Address"])
+
+	// The messages swap names, each keeping the numbers of its own fields
+	file, err = GenerateProto(ProtoAddressesSwapped{}, "", lock)
+	require.NoError(t, err)
+	require.Equal(t, "Address", file.Messages[1].Name)
+	require.Equal(t, []ProtoField{
+		{Type: "string", Name: "name", Number: 1},
+		{Type: "string", Name: "line1", Number: 2},
+		{Type: "string", Name: "country", Number: 3},
+	}, file.Messages[1].Fields)
+	require.Empty(t, file.Messages[1].Reserved)
+	require.Equal(t, "SchematicAddress", file.Messages[2].Name)
+	require.Equal(t, []ProtoField{
+		{Type: "string", Name: "street", Number: 1},
+		{Type: "string", Name: "city", Number: 2},
+		{Type: "string", Name: "zip", Number: 3},
+	}, file.Messages[2].Fields)
+	require.Empty(t, file.Messages[2].Reserved)
+}
+
+type ProtoShipmentV1 struct {
+	ID      string `json:"id"`
+	Carrier string `json:"carrier"`
+}
+
+type ProtoShipmentV2 struct {
+	ID      string `json:"id"`
+	Carrier string `json:"carrier"`
+	Weight  int    `json:"weight" proto:"10"`
+}
+
+func TestProtoNumbersWithoutLock(t *testing.T) {
+	v1, err := GenerateProto(ProtoShipmentV1{}, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, []ProtoField{
+		{Type: "string", Name: "id", Number: 1},
+		{Type: "string", Name: "carrier", Number: 2},
+	}, v1.Messages[0].Fields)
+
+	// A tag added later doesn't move the untagged fields
+	v2, err := GenerateProto(ProtoShipmentV2{}, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, []ProtoField{
+		{Type: "string", Name: "id", Number: 1},
+		{Type: "string", Name: "carrier", Number: 2},
+		{Type: "int64", Name: "weight", Number: 10},
+	}, v2.Messages[0].Fields)
+}
+
+type ProtoNestedCollections struct {
+	Grid  [][][]string       `json:"grid"`
+	Other []map[string][]int `json:"other"`
+}
+
+func TestProtoNestedCollections(t *testing.T) {
+	lock := &ProtoLock{}
+	file, err := GenerateProto(ProtoNestedCollections{}, "", lock)
+	require.NoError(t, err)
+
+	// Every level gets its own wrapper, so no wrapper refers to itself
+	require.Equal(t, `syntax = "proto3";
+
+message ProtoNestedCollections {
+  repeated GridItemItem grid = 1;
+  repeated OtherItemItem other = 2;
+
+  message GridItem {
+    repeated string value = 1;
+  }
+
+  message GridItemItem {
+    repeated GridItem value = 1;
+  }
+
+  message OtherItem {
+    repeated int64 value = 1;
+  }
+
+  message OtherItemItem {
+    map<string, OtherItem> value = 1;
+  }
+}
+`, file.String())
+
+	// Generating again with the lock gives the same numbers and wrappers
+	require.Equal(t, map[string]int{"grid": 1, "other": 2}, lock.Messages["github.com/sadrishehu/schematic/schematic.ProtoNestedCollections"])
+	again, err := GenerateProto(ProtoNestedCollections{}, "", lock)
+	require.NoError(t, err)
+	require.Equal(t, file, again)
+}
+
+func TestInvalidProtoTag(t *testing.T) {
+	type Duplicate struct {
+		A string `proto:"1"`
+		B string `proto:"1"`
+	}
+	type Reserved struct {
+		A string `proto:"19001"`
+	}
+
+	_, err := GenerateProto(Duplicate{}, "", nil)
+	require.EqualError(t, err, "message Duplicate: invalid proto tag on field B: number 1 is used by A too")
+
+	// The lock is left unchanged
+	lock := &ProtoLock{Messages: map[string]map[string]int{}}
+	_, err = GenerateProto(Reserved{}, "", lock)
+	require.EqualError(t, err, `message Reserved: invalid proto tag on field A: "19001" is not a valid field number`)
+	require.Empty(t, lock.Messages)
+
+	_, err = GenerateProto("order", "", nil)
+	require.EqualError(t, err, "protobuf messages must be structs, got string")
+}
+
+func TestBuildProto(t *testing.T) {
+	path := t.TempDir()
+	file, err := GenerateProto(ProtoOrderV1{}, "acme", nil)
+	require.NoError(t, err)
+	require.NoError(t, BuildProto(&path, map[string]ProtoFile{"order.placed": file}))
+
+	data, err := os.ReadFile(filepath.Join(path, "order_placed.proto"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "message ProtoOrderV1 {")
+}
+
+type ProtoOrderPlaced struct {
+	Tags    ProtoTags `json:"tags"`
+	OrderID string    `json:"order_id"`
+}
+
+type ProtoOrderShipped struct {
+	Tags      ProtoTags `json:"tags"`
+	ShippedAt time.Time `json:"shipped_at"`
+}
+
+func TestBuildProtoCommonMessages(t *testing.T) {
+	lock := &ProtoLock{}
+	placed, err := GenerateProto(ProtoOrderPlaced{}, "acme.events", lock)
+	require.NoError(t, err)
+	shipped, err := GenerateProto(ProtoOrderShipped{}, "acme.events", lock)
+	require.NoError(t, err)
+	genProto := map[string]ProtoFile{"order.placed": placed, "order.shipped": shipped}
+
+	// Both files declare ProtoTags in the same package
+	path := t.TempDir()
+	require.EqualError(t, BuildProto(&path, genProto),
+		"message acme.events.ProtoTags is declared by order_placed.proto and order_shipped.proto, write it once with WithCommonDefinitions")
+
+	require.NoError(t, BuildProto(&path, genProto, WithCommonDefinitions("common")))
+
+	common, err := os.ReadFile(filepath.Join(path, "common.proto"))
+	require.NoError(t, err)
+	require.Equal(t, `syntax = "proto3";
+
+package acme.events;
+
+message ProtoTags {
+  string event_name = 1;
+  string event_id = 2 [json_name = "event-id"];
+}
+`, string(common))
+
+	data, err := os.ReadFile(filepath.Join(path, "order_shipped.proto"))
+	require.NoError(t, err)
+	require.Equal(t, `syntax = "proto3";
+
+package acme.events;
+
+import "common.proto";
+import "google/protobuf/timestamp.proto";
+
+message ProtoOrderShipped {
+  ProtoTags tags = 1;
+  google.protobuf.Timestamp shipped_at = 2;
+}
+`, string(data))
+
+	// Files of other packages can't share the common file
+	other, err := GenerateProto(ProtoOrderShipped{}, "acme.shipping", lock)
+	require.NoError(t, err)
+	genProto["order.returned"] = other
+	genProto["order.delivered"] = other
+	require.EqualError(t, BuildProto(&path, genProto, WithCommonDefinitions("common")),
+		"common proto file common.proto can't be shared by the packages acme.shipping and acme.events")
+}
@@ -0,0 +1,141 @@
+package schematic
+
+import (
+	"reflect"
+)
+
+// defaultDefinitionThreshold is the number of properties a nested struct needs to exceed
+// before it is moved to $defs
+const defaultDefinitionThreshold = 2
+
+// Reflector generates JSON Schemas from Go types. Its fields hold the settings used
+// during generation; NewReflector returns one with the defaults applied, which
+// can then be changed through Options or by setting the fields directly.
+type Reflector struct {
+	// NamingStrategy names struct fields that have no name in their json tag.
+	// A nil strategy uses the Go field name like encoding/json.
+	NamingStrategy NamingStrategy
+
+	// DefinitionThreshold is the number of properties above which a nested struct
+	// is placed in $defs and referenced instead of being inlined, when no
+	// DefinitionPolicy is set
+	DefinitionThreshold int
+
+	// DefinitionPolicy decides which structs are placed in $defs. A nil policy
+	// uses ThresholdDefinitions with the DefinitionThreshold.
+	DefinitionPolicy DefinitionPolicy
+
+	// NullablePointers allows null for pointer fields
+	NullablePointers bool
+
+	// SchemaURL is written to the $schema keyword of generated schemas
+	SchemaURL string
+
+	// IDBaseURI is the base URI of the $id BuildEvents gives the generated schemas,
+	// followed by the name of the file it writes them to, e.g. "order_placed.json"
+	IDBaseURI string
+
+	// TypeMappings describes types with a fixed schema instead of reflecting them.
+	// They take precedence over the mappings registered with RegisterType.
+	TypeMappings map[reflect.Type]TypeMapper
+
+	// DefinitionNaming names the $defs entries of named types. A nil naming uses
+	// ShortDefinitionNames. Types that end up with the same name are always
+	// disambiguated by their package.
+	DefinitionNaming DefinitionNaming
+
+	// Warnf, when set, receives warnings about types whose schema can't be
+	// determined precisely, e.g. log.Printf
+	Warnf func(format string, args ...interface{})
+
+	// active is the generation in progress when the Reflector is handed to a ReflectJSONSchemer
+	active *schemaContext
+}
+
+// NewReflector creates a Reflector with the default settings and applies the options
+func NewReflector(opts ...Option) *Reflector {
+	r := &Reflector{
+		NamingStrategy:      GoFieldNames,
+		DefinitionThreshold: defaultDefinitionThreshold,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Reflect creates a complete JSON Schema with definitions from a Go struct value
+func (r *Reflector) Reflect(object interface{}, title string) Schema {
+	t := reflect.TypeOf(object)
+	ctx := r.newContext()
+	properties, required := ctx.buildProperties(t, "")
+
+	// A recursive root refers to itself through $defs
+	if root, ok := structOf(t); ok && ctx.recursive[root] {
+		ctx.createDefinitionReference(root, title, properties, required)
+	}
+
+	resolver := ctx.newResolver(properties)
+	definitions := resolver.definitions()
+
+	schema := Schema{
+		Schema:     r.SchemaURL,
+		Title:      title,
+		Type:       "object",
+		Required:   requiredFields(t, r.naming()),
+		Properties: resolver.properties(properties),
+	}
+
+	if len(definitions) > 0 {
+		schema.Definitions = definitions
+	}
+	schema.idBase = r.IDBaseURI
+
+	return schema
+}
+
+// Properties creates the JSON Schema properties of a Go struct value
+func (r *Reflector) Properties(object interface{}) map[string]PropertyDefinition {
+	ctx := r.newContext()
+	properties, _ := ctx.buildProperties(reflect.TypeOf(object), "")
+	return ctx.newResolver(properties).properties(properties)
+}
+
+// Required lists the JSON names of the required fields of a Go struct type
+func (r *Reflector) Required(t reflect.Type) []string {
+	return requiredFields(t, r.naming())
+}
+
+// naming returns the naming strategy, falling back to the encoding/json behaviour
+func (r *Reflector) naming() NamingStrategy {
+	if r.NamingStrategy == nil {
+		return GoFieldNames
+	}
+	return r.NamingStrategy
+}
+
+// definitionNaming returns the naming of definitions, short type names by default
+func (r *Reflector) definitionNaming() DefinitionNaming {
+	if r.DefinitionNaming == nil {
+		return ShortDefinitionNames
+	}
+	return r.DefinitionNaming
+}
+
+// definitionPolicy returns the policy deciding which structs are placed in $defs
+func (r *Reflector) definitionPolicy() DefinitionPolicy {
+	if r.DefinitionPolicy == nil {
+		return ThresholdDefinitions(r.DefinitionThreshold)
+	}
+	return r.DefinitionPolicy
+}
+
+// newContext creates the state for a single schema generation
+func (r *Reflector) newContext() *schemaContext {
+	return &schemaContext{
+		reflector:   r,
+		warned:      make(map[reflect.Type]bool),
+		definitions: make(map[reflect.Type]*definition),
+		recursive:   make(map[reflect.Type]bool),
+	}
+}
@@ -0,0 +1,128 @@
+package schematic
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReflectorDefaults(t *testing.T) {
+	r := NewReflector(WithSchemaURL("https://json-schema.org/draft/2020-12/schema"))
+
+	schema := r.Reflect(EventToGenerate{}, "Test Event")
+	require.Equal(t, GenerateSchema(EventToGenerate{}, "Test Event", "https://json-schema.org/draft/2020-12/schema"), schema)
+	require.Equal(t, GenerateProperties(EventToGenerate{}), r.Properties(EventToGenerate{}))
+	require.Equal(t, GenerateRequired(EventToGenerate{}, nil), r.Required(reflect.TypeOf(EventToGenerate{})))
+}
+
+func TestReflectorDefinitionThreshold(t *testing.T) {
+	// EventTags has three properties, so it is only inlined with a higher threshold
+	schema := NewReflector().Reflect(EventToGenerate{}, "Test Event")
+	require.Equal(t, "#/$defs/EventTags", schema.Properties["tags"].Ref)
+
+	schema = NewReflector(WithDefinitionThreshold(3)).Reflect(EventToGenerate{}, "Test Event")
+	require.Empty(t, schema.Properties["tags"].Ref)
+	require.Len(t, schema.Properties["tags"].Properties, 3)
+	require.NotContains(t, schema.Definitions, "EventTags")
+}
+
+func TestReflectorFields(t *testing.T) {
+	// Settings can be changed on the struct directly, a zero Reflector uses encoding/json names
+	r := &Reflector{DefinitionThreshold: 10}
+	require.Contains(t, r.Properties(NullableStruct{}), "Scores")
+
+	r.NamingStrategy = SnakeCase
+	r.NullablePointers = true
+	properties := r.Properties(NullableStruct{})
+	require.Contains(t, properties, "scores")
+	require.Equal(t, SchemaType{"string", "null"}, properties["nickname"].Type)
+}
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type Route struct {
+	From    Point    `json:"from"`
+	To      Point    `json:"to"`
+	Stops   []Point  `json:"stops"`
+	Carrier *Address `json:"carrier"`
+	Meta    struct {
+		Source  string `json:"source"`
+		Version int    `json:"version"`
+		Comment string `json:"comment"`
+	} `json:"meta"`
+}
+
+func TestReflectorDefinitionPolicy(t *testing.T) {
+	definitions := func(schema Schema) []string {
+		names := []string{}
+		for name := range schema.Definitions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// The threshold applies to pointers, slice items and anonymous structs alike
+	schema := NewReflector().Reflect(Route{}, "Route")
+	require.Equal(t, []string{"Address", "RouteMeta"}, definitions(schema))
+	require.Equal(t, SchemaType{"object"}, schema.Properties["stops"].Items.Type)
+	require.Equal(t, "#/$defs/Address", schema.Properties["carrier"].Ref)
+
+	schema = NewReflector(WithDefinitionPolicy(NamedDefinitions)).Reflect(Route{}, "Route")
+	require.Equal(t, []string{"Address", "Point"}, definitions(schema))
+	require.Equal(t, "#/$defs/Point", schema.Properties["stops"].Items.Ref)
+	require.Equal(t, "Meta", schema.Properties["meta"].Description)
+
+	// Only Point is used more than once
+	schema = NewReflector(WithDefinitionPolicy(ReusedDefinitions)).Reflect(Route{}, "Route")
+	require.Equal(t, []string{"Point"}, definitions(schema))
+	require.Equal(t, "#/$defs/Point", schema.Properties["from"].Ref)
+	require.Equal(t, "From", schema.Properties["from"].Description)
+	require.Equal(t, "To", schema.Properties["to"].Description)
+	require.Len(t, schema.Properties["carrier"].Properties, 3)
+
+	schema = NewReflector(WithDefinitionPolicy(InlineDefinitions), WithNullablePointers()).Reflect(Route{}, "Route")
+	require.Nil(t, schema.Definitions)
+	require.Equal(t, SchemaType{"object", "null"}, schema.Properties["carrier"].Type)
+	require.Equal(t, "Carrier", schema.Properties["carrier"].Description)
+	require.Equal(t, SchemaType{"integer"}, schema.Properties["stops"].Items.Properties["x"].Type)
+
+	payload := `{"from": {"x": 1, "y": 2}, "to": {"x": 3, "y": 4}, "stops": [{"x": 5, "y": 6}], "carrier": null,
+		"meta": {"source": "a", "version": 1, "comment": "b"}}`
+	require.Empty(t, Validate(schema, []byte(payload)))
+
+	// Recursive types are kept whatever the policy
+	schema = NewReflector(WithDefinitionPolicy(InlineDefinitions)).Reflect(Thread{}, "Thread")
+	require.Equal(t, []string{"Comment"}, definitions(schema))
+}
+
+func TestReflectorDefinitionPolicyCallback(t *testing.T) {
+	var calls []string
+	policy := func(t reflect.Type, property PropertyDefinition, uses int) bool {
+		calls = append(calls, fmt.Sprintf("%s:%d:%d", t.Name(), len(property.Properties), uses))
+		return t == reflect.TypeOf(Point{})
+	}
+
+	schema := NewReflector(WithDefinitionPolicy(policy)).Reflect(Route{}, "Route")
+	require.Equal(t, []string{"Point:2:3", "Address:3:1", ":3:1"}, calls)
+	require.Equal(t, "#/$defs/Point", schema.Properties["stops"].Items.Ref)
+	require.Len(t, schema.Definitions, 1)
+}
+
+func TestReflectorIDBaseURI(t *testing.T) {
+	// The $id is only made by BuildEvents, which knows the file name
+	schema := NewReflector(WithIDBaseURI("https://schemas.acme.com/events/")).Reflect(EventToGenerate{}, "Test Event")
+	require.Empty(t, schema.ID)
+	require.Equal(t, "https://schemas.acme.com/events/", schema.idBase)
+
+	schema = GenerateSchema(EventToGenerate{}, "Test Event", "", WithIDBaseURI("https://schemas.acme.com/"))
+	require.Equal(t, "https://schemas.acme.com/", schema.idBase)
+
+	require.Empty(t, NewReflector().Reflect(EventToGenerate{}, "Test Event").idBase)
+}
@@ -0,0 +1,91 @@
+package schematic
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// TypeMapper returns the schema describing values of a registered type
+type TypeMapper func(t reflect.Type) PropertyDefinition
+
+// globalTypeMappings holds the mappings registered with RegisterType and RegisterTypeFunc
+var globalTypeMappings = struct {
+	sync.RWMutex
+	mappers map[reflect.Type]TypeMapper
+}{mappers: map[reflect.Type]TypeMapper{}}
+
+// builtinTypes maps standard library types whose printed name differs between Go
+// versions and therefore can't be matched by typeMapping. They are consulted after
+// the registered mappings.
+var builtinTypes = map[reflect.Type]PropertyDefinition{
+	reflect.TypeOf(json.RawMessage{}): {Type: SchemaType{"string"}},
+}
+
+// RegisterType makes every Reflector describe values of type t with def instead of
+// reflecting the type. Types are matched exactly, so two packages' UUID types can be
+// registered independently. Pointers to t use the same schema.
+//
+//	schematic.RegisterType(reflect.TypeOf(decimal.Decimal{}), schematic.PropertyDefinition{
+//		Type:    schematic.SchemaType{"string"},
+//		Pattern: `^-?[0-9]+(\.[0-9]+)?$`,
+//	})
+func RegisterType(t reflect.Type, def PropertyDefinition) {
+	RegisterTypeFunc(t, staticMapper(def))
+}
+
+// RegisterTypeFunc makes every Reflector describe values of type t with the schema
+// returned by fn instead of reflecting the type
+func RegisterTypeFunc(t reflect.Type, fn TypeMapper) {
+	globalTypeMappings.Lock()
+	defer globalTypeMappings.Unlock()
+
+	globalTypeMappings.mappers[t] = fn
+}
+
+// RegisterType makes this Reflector describe values of type t with def. It takes
+// precedence over mappings registered with the package level RegisterType.
+func (r *Reflector) RegisterType(t reflect.Type, def PropertyDefinition) {
+	r.RegisterTypeFunc(t, staticMapper(def))
+}
+
+// RegisterTypeFunc makes this Reflector describe values of type t with the schema
+// returned by fn. It takes precedence over the package level mappings.
+func (r *Reflector) RegisterTypeFunc(t reflect.Type, fn TypeMapper) {
+	if r.TypeMappings == nil {
+		r.TypeMappings = map[reflect.Type]TypeMapper{}
+	}
+	r.TypeMappings[t] = fn
+}
+
+// lookupType returns the registered or built-in schema for t or for the type t points to
+func (r *Reflector) lookupType(t reflect.Type) (PropertyDefinition, bool) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if fn, ok := r.TypeMappings[t]; ok {
+		return fn(t).clone(), true
+	}
+
+	globalTypeMappings.RLock()
+	fn, ok := globalTypeMappings.mappers[t]
+	globalTypeMappings.RUnlock()
+
+	if ok {
+		return fn(t).clone(), true
+	}
+
+	if def, ok := builtinTypes[t]; ok {
+		return def.clone(), true
+	}
+
+	return PropertyDefinition{}, false
+}
+
+// staticMapper returns a TypeMapper that always describes a type with def
+func staticMapper(def PropertyDefinition) TypeMapper {
+	return func(reflect.Type) PropertyDefinition {
+		return def
+	}
+}
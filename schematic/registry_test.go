@@ -0,0 +1,98 @@
+package schematic
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type CivilDate struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+type Invoice struct {
+	Total    Money            `json:"total"`
+	Discount *Money           `json:"discount"`
+	Lines    []Money          `json:"lines"`
+	ByItem   map[string]Money `json:"by_item"`
+	Due      CivilDate        `json:"due" jsonschema:"pattern=^2"`
+}
+
+var moneyDefinition = PropertyDefinition{
+	Type:    SchemaType{"string"},
+	Pattern: `^[A-Z]{3} -?[0-9]+\.[0-9]{2}$`,
+}
+
+func TestReflectorTypeMapping(t *testing.T) {
+	r := NewReflector(WithTypeMapping(reflect.TypeOf(Money{}), moneyDefinition), WithNullablePointers())
+	properties := r.Properties(Invoice{})
+
+	require.Equal(t, SchemaType{"string"}, properties["total"].Type)
+	require.Equal(t, moneyDefinition.Pattern, properties["total"].Pattern)
+	require.Equal(t, "Total", properties["total"].Description)
+	require.Empty(t, properties["total"].Properties)
+
+	require.Equal(t, SchemaType{"string", "null"}, properties["discount"].Type)
+	require.Equal(t, SchemaType{"string"}, properties["lines"].Items.Type)
+	require.Equal(t, SchemaType{"string"}, properties["by_item"].AdditionalProperties.Type)
+
+	// The registered definition itself is never modified
+	require.Equal(t, SchemaType{"string"}, moneyDefinition.Type)
+
+	// Unregistered types are reflected as before
+	require.Equal(t, "#/$defs/CivilDate", properties["due"].Ref)
+}
+
+func TestReflectorTypeMapper(t *testing.T) {
+	r := NewReflector()
+	r.RegisterTypeFunc(reflect.TypeOf(CivilDate{}), func(t reflect.Type) PropertyDefinition {
+		return PropertyDefinition{Type: SchemaType{"string"}, Format: "date", Description: t.Name()}
+	})
+
+	properties := r.Properties(Invoice{})
+	require.Equal(t, "date", properties["due"].Format)
+	require.Equal(t, "CivilDate", properties["due"].Description)
+	// Tag keywords are still applied on top of the mapping
+	require.Equal(t, "^2", properties["due"].Pattern)
+}
+
+func TestRegisterTypeByIdentity(t *testing.T) {
+	type UUID [16]byte
+	first := reflect.TypeOf(UUID{})
+
+	type Holder struct {
+		ID UUID `json:"id"`
+	}
+
+	otherHolder := func() interface{} {
+		type UUID [16]byte
+		type Holder struct {
+			ID UUID `json:"id"`
+		}
+		return Holder{}
+	}()
+
+	RegisterType(first, PropertyDefinition{Type: SchemaType{"string"}, Format: "uuid"})
+	t.Cleanup(func() {
+		globalTypeMappings.Lock()
+		delete(globalTypeMappings.mappers, first)
+		globalTypeMappings.Unlock()
+	})
+
+	require.Equal(t, "uuid", GenerateProperties(Holder{})["id"].Format)
+
+	// A different type with the same name is not affected
+	require.NotEqual(t, "uuid", NewReflector().Properties(otherHolder)["id"].Format)
+
+	// Reflector mappings take precedence over global ones
+	r := NewReflector(WithTypeMapping(first, PropertyDefinition{Type: SchemaType{"string"}, Format: "hex"}))
+	require.Equal(t, "hex", r.Properties(Holder{})["id"].Format)
+}
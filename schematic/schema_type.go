@@ -0,0 +1,72 @@
+package schematic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const typeNull string = "null"
+
+// SchemaType is the value of the JSON Schema "type" keyword. A single type is
+// marshaled as a plain string (`"string"`), several types as an array
+// (`["string","null"]`).
+type SchemaType []string
+
+// typeOf returns a SchemaType holding a single type, or no type for an empty name
+func typeOf(name string) SchemaType {
+	if name == "" {
+		return nil
+	}
+	return SchemaType{name}
+}
+
+// String returns the types joined for use in messages, e.g. "string or null"
+func (t SchemaType) String() string {
+	return strings.Join(t, " or ")
+}
+
+// Includes reports whether name is one of the types
+func (t SchemaType) Includes(name string) bool {
+	for _, n := range t {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Primary returns the first type that is not "null", or an empty string
+func (t SchemaType) Primary() string {
+	for _, n := range t {
+		if n != typeNull {
+			return n
+		}
+	}
+	return ""
+}
+
+// MarshalJSON encodes a single type as a string and several types as an array
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON accepts both the string and the array form of "type"
+func (t *SchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = typeOf(single)
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings: %w", err)
+	}
+	*t = list
+
+	return nil
+}
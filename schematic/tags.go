@@ -0,0 +1,219 @@
+package schematic
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// constraints holds the validation keywords parsed from a `jsonschema` struct tag
+type constraints struct {
+	Enum             []string
+	Minimum          *float64
+	Maximum          *float64
+	ExclusiveMinimum *float64
+	ExclusiveMaximum *float64
+	MultipleOf       *float64
+	MinLength        *int
+	MaxLength        *int
+	Pattern          string
+	Format           string
+	MinItems         *int
+	MaxItems         *int
+	UniqueItems      bool
+}
+
+// constraintKeywords lists every keyword understood inside a `jsonschema` tag
+var constraintKeywords = map[string]bool{
+	"enum":             true,
+	"minimum":          true,
+	"maximum":          true,
+	"exclusiveMinimum": true,
+	"exclusiveMaximum": true,
+	"multipleOf":       true,
+	"minLength":        true,
+	"maxLength":        true,
+	"pattern":          true,
+	"format":           true,
+	"minItems":         true,
+	"maxItems":         true,
+	"uniqueItems":      true,
+}
+
+// tagKeyword matches the keywords of `jsonschema` tags, including the ones of other
+// libraries such as title or description
+var tagKeyword = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// parseConstraints parses a `jsonschema` tag such as
+// `jsonschema:"minimum=0,maximum=100,pattern=^[a-z]+$,enum=a|b|c,uniqueItems"`.
+// Values may contain commas (e.g. `pattern=^a{1,3}$`); a comma only starts a new
+// entry when it is followed by a keyword. Unknown keywords and invalid values are
+// skipped and returned as errors, so that tags written for other libraries don't
+// prevent the schema from being generated.
+func parseConstraints(tag string) (constraints, []error) {
+	var c constraints
+
+	if tag == "" {
+		return c, nil
+	}
+
+	var entries []string
+	for _, part := range strings.Split(tag, ",") {
+		if len(entries) > 0 && !startsEntry(entries[len(entries)-1], part) {
+			entries[len(entries)-1] += "," + part
+			continue
+		}
+		entries = append(entries, part)
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		key, value, hasValue := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+
+		var err error
+		switch key {
+		case "enum":
+			c.Enum = strings.Split(value, "|")
+		case "minimum":
+			c.Minimum, err = parseFloat(value)
+		case "maximum":
+			c.Maximum, err = parseFloat(value)
+		case "exclusiveMinimum":
+			c.ExclusiveMinimum, err = parseFloat(value)
+		case "exclusiveMaximum":
+			c.ExclusiveMaximum, err = parseFloat(value)
+		case "multipleOf":
+			c.MultipleOf, err = parseFloat(value)
+		case "minLength":
+			c.MinLength, err = parseInt(value)
+		case "maxLength":
+			c.MaxLength, err = parseInt(value)
+		case "pattern":
+			c.Pattern = value
+		case "format":
+			c.Format = value
+		case "minItems":
+			c.MinItems, err = parseInt(value)
+		case "maxItems":
+			c.MaxItems, err = parseInt(value)
+		case "uniqueItems":
+			c.UniqueItems = !hasValue || value == "true"
+		default:
+			errs = append(errs, fmt.Errorf("unknown keyword %q", key))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for %s: %w", key, err))
+		}
+	}
+
+	return c, errs
+}
+
+// startsEntry reports whether the part of a tag following a comma starts a new
+// entry rather than continuing the value of the previous one. A word without a
+// value after a pattern, as in `pattern=a,b`, is part of the pattern.
+func startsEntry(previous, part string) bool {
+	key, _, hasValue := strings.Cut(part, "=")
+	key = strings.TrimSpace(key)
+	if constraintKeywords[key] {
+		return true
+	}
+	if !tagKeyword.MatchString(key) {
+		return false
+	}
+
+	previousKey, _, _ := strings.Cut(previous, "=")
+	return hasValue || strings.TrimSpace(previousKey) != "pattern"
+}
+
+func parseFloat(value string) (*float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func parseInt(value string) (*int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// apply copies the constraints onto a property, keeping whatever the property
+// already declares for keywords absent from the tag. For arrays the item level
+// keywords (enum, bounds, lengths, pattern, format) are applied to the items
+// schema while the array keywords stay on the property itself.
+func (c constraints) apply(property *PropertyDefinition) {
+	setInt(&property.MinItems, c.MinItems)
+	setInt(&property.MaxItems, c.MaxItems)
+	if c.UniqueItems {
+		property.UniqueItems = true
+	}
+
+	target := property
+	if property.Type.Primary() == typeArray && property.Items != nil {
+		target = property.Items
+	}
+
+	if len(c.Enum) > 0 {
+		target.Enum = enumValues(c.Enum, target.Type.Primary())
+	}
+	setFloat(&target.Minimum, c.Minimum)
+	setFloat(&target.Maximum, c.Maximum)
+	setFloat(&target.ExclusiveMinimum, c.ExclusiveMinimum)
+	setFloat(&target.ExclusiveMaximum, c.ExclusiveMaximum)
+	setFloat(&target.MultipleOf, c.MultipleOf)
+	setInt(&target.MinLength, c.MinLength)
+	setInt(&target.MaxLength, c.MaxLength)
+	if c.Pattern != "" {
+		target.Pattern = c.Pattern
+	}
+	if c.Format != "" {
+		target.Format = c.Format
+	}
+}
+
+func setFloat(target **float64, value *float64) {
+	if value != nil {
+		*target = value
+	}
+}
+
+func setInt(target **int, value *int) {
+	if value != nil {
+		*target = value
+	}
+}
+
+// enumValues converts the raw enum strings to the JSON type of the property so
+// that e.g. `enum=1|2|3` on an int field produces numbers rather than strings
+func enumValues(raw []string, jsonType string) []interface{} {
+	values := make([]interface{}, 0, len(raw))
+
+	for _, r := range raw {
+		var value interface{} = r
+		switch jsonType {
+		case "integer":
+			if i, err := strconv.ParseInt(r, 10, 64); err == nil {
+				value = i
+			}
+		case "number":
+			if f, err := strconv.ParseFloat(r, 64); err == nil {
+				value = f
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(r); err == nil {
+				value = b
+			}
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
@@ -0,0 +1,284 @@
+package schematic
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tsIdentifier matches the property names that can be written without quotes
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// typeNameSeparator matches the characters dropped from definition names and titles
+// when they are turned into type names
+var typeNameSeparator = regexp.MustCompile(`[^\p{L}\p{N}_$]+`)
+
+// GenerateTypeScript creates TypeScript declarations for a schema: an interface
+// named after its title describing the event and a type for every definition. A
+// title taken by a definition gets an "Event" suffix, e.g. "AddressEvent".
+// Properties that aren't required are optional, enums are unions of literals and
+// descriptions become JSDoc comments.
+func GenerateTypeScript(schema Schema) string {
+	var b strings.Builder
+
+	names := make([]string, 0, len(schema.Definitions))
+	taken := make(map[string]bool, len(schema.Definitions))
+	for name := range schema.Definitions {
+		names = append(names, name)
+		taken[pascalTypeName(name)] = true
+	}
+	sort.Strings(names)
+
+	// The references keep the names of the definitions, so a root named like one
+	// of them is renamed instead
+	rootName := pascalTypeName(schema.Title)
+	if taken[rootName] {
+		rootName += "Event"
+	}
+	base := rootName
+	for suffix := 2; taken[rootName]; suffix++ {
+		rootName = base + strconv.Itoa(suffix)
+	}
+
+	root := rootProperty(schema)
+	root.Description = schema.Title
+	if root.Type == nil {
+		root.Type = typeOf("object")
+	}
+	writeTSDeclaration(&b, rootName, root)
+
+	for _, name := range names {
+		b.WriteString("\n")
+		writeTSDeclaration(&b, pascalTypeName(name), schema.Definitions[name])
+	}
+
+	return b.String()
+}
+
+// writeTSDeclaration writes an interface for an object with properties and a type
+// alias for anything else
+func writeTSDeclaration(b *strings.Builder, name string, property PropertyDefinition) {
+	writeJSDoc(b, "", property)
+	if property.Type.Primary() == "object" && tsInterface(property) {
+		fmt.Fprintf(b, "export interface %s %s\n", name, tsObject(property, ""))
+		return
+	}
+	fmt.Fprintf(b, "export type %s = %s;\n", name, tsType(property, ""))
+}
+
+// writeJSDoc writes the description and format of a property as a JSDoc comment
+func writeJSDoc(b *strings.Builder, indent string, property PropertyDefinition) {
+	var lines []string
+	if property.Description != "" {
+		lines = append(lines, strings.Split(property.Description, "\n")...)
+	}
+	if property.Format != "" {
+		lines = append(lines, "@format "+property.Format)
+	}
+
+	switch len(lines) {
+	case 0:
+		return
+	case 1:
+		fmt.Fprintf(b, "%s/** %s */\n", indent, escapeJSDoc(lines[0]))
+		return
+	}
+	fmt.Fprintf(b, "%s/**\n", indent)
+	for _, line := range lines {
+		fmt.Fprintf(b, "%s * %s\n", indent, escapeJSDoc(line))
+	}
+	fmt.Fprintf(b, "%s */\n", indent)
+}
+
+// escapeJSDoc keeps a description from closing its comment
+func escapeJSDoc(line string) string {
+	return strings.ReplaceAll(line, "*/", "*\\/")
+}
+
+// tsType returns the TypeScript type of a property. indent is the indentation of
+// the line the type starts on, used by object literals.
+func tsType(property PropertyDefinition, indent string) string {
+	if rejectsAll(property) {
+		return "never"
+	}
+	if property.Ref != "" {
+		return tsRefName(property.Ref)
+	}
+
+	if len(property.Enum) > 0 {
+		literals := make([]string, len(property.Enum))
+		for i, value := range property.Enum {
+			literal, err := json.Marshal(value)
+			if err != nil {
+				literal = []byte("unknown")
+			}
+			literals[i] = string(literal)
+		}
+		return strings.Join(literals, " | ")
+	}
+
+	if len(property.AnyOf) > 0 {
+		types := make([]string, len(property.AnyOf))
+		for i, schema := range property.AnyOf {
+			types[i] = tsType(schema, indent)
+		}
+		return strings.Join(types, " | ")
+	}
+
+	if len(property.Type) == 0 {
+		return "unknown"
+	}
+
+	types := make([]string, len(property.Type))
+	for i, name := range property.Type {
+		types[i] = tsSingleType(name, property, indent)
+	}
+	return strings.Join(types, " | ")
+}
+
+// tsSingleType returns the TypeScript type of a property for one of its JSON types
+func tsSingleType(name string, property PropertyDefinition, indent string) string {
+	switch name {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case typeNull:
+		return "null"
+	case typeArray:
+		items := "unknown"
+		if property.Items != nil {
+			items = tsType(*property.Items, indent)
+		}
+		if len(property.PrefixItems) == 0 {
+			return tsGroup(items) + "[]"
+		}
+
+		elements := make([]string, len(property.PrefixItems))
+		for i, schema := range property.PrefixItems {
+			elements[i] = tsType(schema, indent)
+		}
+		if property.Items != nil {
+			elements = append(elements, "..."+tsGroup(items)+"[]")
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case "object":
+		values := "unknown"
+		if property.AdditionalProperties != nil {
+			values = tsType(*property.AdditionalProperties, indent)
+		}
+		record := "Record<string, " + values + ">"
+		if property.Properties == nil {
+			return record
+		}
+		if tsInterface(property) {
+			return tsObject(property, indent)
+		}
+		return tsObject(property, indent) + " & " + record
+	}
+	return "unknown"
+}
+
+// tsInterface reports whether an object with properties is described by them alone,
+// without a record of the other properties: it says nothing about them or, like
+// `"additionalProperties": false`, allows none
+func tsInterface(property PropertyDefinition) bool {
+	additional := property.AdditionalProperties
+	return property.Properties != nil && (additional == nil || rejectsAll(*additional))
+}
+
+// tsObject returns an object literal type holding the properties of an object
+func tsObject(property PropertyDefinition, indent string) string {
+	if len(property.Properties) == 0 {
+		return "{}"
+	}
+
+	required := make(map[string]bool, len(property.Required))
+	for _, name := range property.Required {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(property.Properties))
+	for name := range property.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("{\n")
+	inner := indent + "  "
+	for _, name := range names {
+		field := property.Properties[name]
+		writeJSDoc(&b, inner, field)
+
+		key := name
+		if !tsIdentifier.MatchString(name) {
+			key = fmt.Sprintf("%q", name)
+		}
+		optional := ""
+		if !required[name] {
+			optional = "?"
+		}
+		fmt.Fprintf(&b, "%s%s%s: %s;\n", inner, key, optional, tsType(field, inner))
+	}
+	b.WriteString(indent + "}")
+
+	return b.String()
+}
+
+// tsGroup wraps a union in parentheses so that it can be followed by []
+func tsGroup(t string) string {
+	if strings.Contains(t, " | ") || strings.Contains(t, " & ") {
+		return "(" + t + ")"
+	}
+	return t
+}
+
+// tsRefName returns the type name a reference to a definition refers to
+func tsRefName(ref string) string {
+	if !strings.HasPrefix(ref, defsRefPrefix) {
+		return "unknown"
+	}
+	return pascalTypeName(unescapePointer(strings.TrimPrefix(ref, defsRefPrefix)))
+}
+
+// pascalTypeName turns a definition name or a title into a type name,
+// e.g. "billing.Address" into "BillingAddress" and "Cute Event Name" into "CuteEventName"
+func pascalTypeName(name string) string {
+	var b strings.Builder
+	for _, part := range typeNameSeparator.Split(name, -1) {
+		if part == "" {
+			continue
+		}
+		runes := []rune(part)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+
+	typeName := b.String()
+	if typeName == "" || unicode.IsDigit([]rune(typeName)[0]) {
+		typeName = "T" + typeName
+	}
+	return typeName
+}
+
+// BuildTypeScript writes the TypeScript declarations of events to .d.ts files named
+// like the JSON Schema files written by BuildEvents. Events without a title are
+// named after the event.
+func BuildTypeScript(path *string, genSchema map[string]Schema) error {
+	files := make(map[string][]byte, len(genSchema))
+	for name, schema := range genSchema {
+		if schema.Title == "" {
+			schema.Title = name
+		}
+		files[eventFileName(name, ".d.ts")] = []byte(GenerateTypeScript(schema))
+	}
+
+	return writeFiles(path, files)
+}
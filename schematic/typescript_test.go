@@ -0,0 +1,155 @@
+package schematic
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type TSCustomer struct {
+	Name    string `json:"name"`
+	Email   string `json:"email,omitempty" jsonschema:"format=email"`
+	Country string `json:"country"`
+}
+
+type TSOrder struct {
+	ID        string                `json:"id"`
+	Status    string                `json:"status" jsonschema:"enum=open|closed"`
+	Customer  TSCustomer            `json:"customer"`
+	Lines     []TSCustomer          `json:"lines"`
+	Note      *string               `json:"note"`
+	Meta      map[string][]int      `json:"meta-data"`
+	Shipping  struct{ City string } `json:"shipping"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+func TestTypeScript(t *testing.T) {
+	schema := GenerateSchema(TSOrder{}, "Order Placed", string(Draft202012), WithNullablePointers())
+
+	require.Equal(t, `/** Order Placed */
+export interface OrderPlaced {
+  /**
+   * CreatedAt
+   * @format date-time
+   */
+  created_at: string;
+  /** Customer */
+  customer: TSCustomer;
+  /** ID */
+  id: string;
+  /** Lines */
+  lines?: TSCustomer[];
+  /** Meta */
+  "meta-data": Record<string, number[]>;
+  /** Note */
+  note?: string | null;
+  /** Shipping */
+  shipping: {
+    /** City */
+    City: string;
+  };
+  /** Status */
+  status: "open" | "closed";
+}
+
+/** Customer */
+export interface TSCustomer {
+  /** Country */
+  country: string;
+  /**
+   * Email
+   * @format email
+   */
+  email?: string;
+  /** Name */
+  name: string;
+}
+`, GenerateTypeScript(schema))
+}
+
+func TestTypeScriptTypes(t *testing.T) {
+	tests := []struct {
+		property PropertyDefinition
+		expected string
+	}{
+		{PropertyDefinition{}, "unknown"},
+		{PropertyDefinition{Type: SchemaType{"integer", "null"}, Enum: []interface{}{1, 2, nil}}, "1 | 2 | null"},
+		{PropertyDefinition{AnyOf: []PropertyDefinition{{Ref: "#/$defs/billing.Address"}, {Type: typeOf(typeNull)}}}, "BillingAddress | null"},
+		{PropertyDefinition{Type: typeOf(typeArray), Items: &PropertyDefinition{Type: SchemaType{"string", "null"}}}, "(string | null)[]"},
+		{PropertyDefinition{Type: typeOf(typeArray), PrefixItems: []PropertyDefinition{{Type: typeOf("string")}, {Type: typeOf("number")}}}, "[string, number]"},
+		{PropertyDefinition{Type: typeOf("object")}, "Record<string, unknown>"},
+		{PropertyDefinition{Not: &PropertyDefinition{}}, "never"},
+		{PropertyDefinition{Type: typeOf("object"), AdditionalProperties: &PropertyDefinition{Not: &PropertyDefinition{}}}, "Record<string, never>"},
+		{PropertyDefinition{Type: typeOf("object"), Properties: map[string]PropertyDefinition{"id": {Type: typeOf("string")}},
+			Required: []string{"id"}, AdditionalProperties: &PropertyDefinition{Not: &PropertyDefinition{}}}, "{\n  id: string;\n}"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, tsType(tt.property, ""))
+	}
+}
+
+func TestTypeScriptSchemaReadBack(t *testing.T) {
+	var schema Schema
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"title": "Order Placed",
+		"type": "object",
+		"properties": {
+			"id": {"type": "string"},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"customer": {"type": "object", "properties": {"name": {"type": "string"}}, "additionalProperties": false}
+		},
+		"required": ["id"],
+		"additionalProperties": {"type": "number"}
+	}`), &schema))
+
+	// The additionalProperties of the root are kept and closed objects are plain interfaces
+	require.Equal(t, `/** Order Placed */
+export type OrderPlaced = {
+  customer?: {
+    name?: string;
+  };
+  id: string;
+  labels?: Record<string, string>;
+} & Record<string, number>;
+`, GenerateTypeScript(schema))
+
+	schema.AdditionalProperties = &PropertyDefinition{Not: &PropertyDefinition{}}
+	require.Contains(t, GenerateTypeScript(schema), "export interface OrderPlaced {")
+}
+
+func TestTypeScriptRootNameClash(t *testing.T) {
+	schema := Schema{
+		Title:      "Address",
+		Type:       "object",
+		Properties: map[string]PropertyDefinition{"billing": {Ref: "#/$defs/Address"}},
+		Required:   []string{"billing"},
+		Definitions: map[string]PropertyDefinition{
+			"Address": {Type: typeOf("string")},
+		},
+	}
+	require.Equal(t, `/** Address */
+export interface AddressEvent {
+  billing: Address;
+}
+
+export type Address = string;
+`, GenerateTypeScript(schema))
+
+	// The suffixed name is numbered when it is taken too
+	schema.Definitions["address.event"] = PropertyDefinition{Type: typeOf("integer")}
+	require.Contains(t, GenerateTypeScript(schema), "export interface AddressEvent2 {")
+}
+
+func TestBuildTypeScript(t *testing.T) {
+	path := t.TempDir()
+	require.NoError(t, BuildTypeScript(&path, map[string]Schema{"order.placed": GenerateSchema(TSCustomer{}, "", "")}))
+
+	data, err := os.ReadFile(filepath.Join(path, "order_placed.d.ts"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "export interface OrderPlaced {")
+}
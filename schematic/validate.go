@@ -0,0 +1,404 @@
+package schematic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidationError describes a single violation of a schema by a payload
+type ValidationError struct {
+	// Path is a JSON pointer (RFC 6901) to the offending value, empty for the document root
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("%s: %s", path, e.Message)
+}
+
+// Validate checks a JSON payload against a generated Schema and returns every
+// violation found. An empty result means the payload is valid.
+func Validate(schema Schema, payload []byte) []ValidationError {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []ValidationError{{Message: fmt.Sprintf("invalid JSON: %s", err)}}
+	}
+	if decoder.More() {
+		return []ValidationError{{Message: "invalid JSON: unexpected data after top-level value"}}
+	}
+
+	v := &validator{definitions: schema.Definitions}
+	v.validate(rootProperty(schema), value, "")
+
+	return v.errors
+}
+
+// validator walks a decoded payload alongside its schema collecting errors
+type validator struct {
+	definitions map[string]PropertyDefinition
+	errors      []ValidationError
+}
+
+func (v *validator) addError(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(def PropertyDefinition, value interface{}, path string) {
+	if def.Ref != "" {
+		resolved, err := v.resolve(def.Ref)
+		if err != nil {
+			v.addError(path, "%s", err)
+			return
+		}
+		v.validate(resolved, value, path)
+		def.Ref = ""
+	}
+
+	if len(def.Type) > 0 && !matchesAnyType(def.Type, value) {
+		v.addError(path, "expected %s, got %s", def.Type, jsonTypeOf(value))
+		return
+	}
+
+	if len(def.AnyOf) > 0 && !v.matchesAnyOf(def.AnyOf, value, path) {
+		v.addError(path, "value does not match any of the allowed schemas")
+	}
+
+	if def.Not != nil && v.matchesAnyOf([]PropertyDefinition{*def.Not}, value, path) {
+		if rejectsAll(def) {
+			v.addError(path, "no value is allowed")
+		} else {
+			v.addError(path, "value matches a schema it must not match")
+		}
+	}
+
+	if len(def.Enum) > 0 && !containsValue(def.Enum, value) {
+		v.addError(path, "value %s is not one of the allowed values", encodeValue(value))
+	}
+
+	switch value := value.(type) {
+	case json.Number:
+		v.validateNumber(def, value, path)
+	case string:
+		v.validateString(def, value, path)
+	case []interface{}:
+		v.validateArray(def, value, path)
+	case map[string]interface{}:
+		v.validateObject(def, value, path)
+	}
+}
+
+// matchesAnyOf reports whether value is valid against at least one of the schemas
+func (v *validator) matchesAnyOf(schemas []PropertyDefinition, value interface{}, path string) bool {
+	for _, schema := range schemas {
+		branch := &validator{definitions: v.definitions}
+		branch.validate(schema, value, path)
+		if len(branch.errors) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve looks up a local "#/$defs/..." reference
+func (v *validator) resolve(ref string) (PropertyDefinition, error) {
+	if !strings.HasPrefix(ref, defsRefPrefix) {
+		return PropertyDefinition{}, fmt.Errorf("unsupported reference %q", ref)
+	}
+
+	name := unescapePointer(strings.TrimPrefix(ref, defsRefPrefix))
+	def, ok := v.definitions[name]
+	if !ok {
+		return PropertyDefinition{}, fmt.Errorf("unresolved reference %q", ref)
+	}
+
+	return def, nil
+}
+
+func (v *validator) validateNumber(def PropertyDefinition, value json.Number, path string) {
+	n, err := value.Float64()
+	if err != nil {
+		v.addError(path, "invalid number %s", value)
+		return
+	}
+
+	if def.Minimum != nil && n < *def.Minimum {
+		v.addError(path, "%s is less than minimum %v", value, *def.Minimum)
+	}
+	if def.Maximum != nil && n > *def.Maximum {
+		v.addError(path, "%s is greater than maximum %v", value, *def.Maximum)
+	}
+	if def.ExclusiveMinimum != nil && n <= *def.ExclusiveMinimum {
+		v.addError(path, "%s is not greater than %v", value, *def.ExclusiveMinimum)
+	}
+	if def.ExclusiveMaximum != nil && n >= *def.ExclusiveMaximum {
+		v.addError(path, "%s is not less than %v", value, *def.ExclusiveMaximum)
+	}
+	if def.MultipleOf != nil && *def.MultipleOf != 0 && !isMultipleOf(value, *def.MultipleOf) {
+		v.addError(path, "%s is not a multiple of %v", value, *def.MultipleOf)
+	}
+}
+
+// isMultipleOf reports whether a number is a multiple of m. Both are compared as
+// exact decimals, so 19.99 is a multiple of 0.01 although neither is in binary.
+func isMultipleOf(value json.Number, m float64) bool {
+	n, ok := new(big.Rat).SetString(value.String())
+	if !ok {
+		return false
+	}
+	divisor, ok := new(big.Rat).SetString(strconv.FormatFloat(m, 'g', -1, 64))
+	if !ok {
+		return false
+	}
+	return new(big.Rat).Quo(n, divisor).IsInt()
+}
+
+func (v *validator) validateString(def PropertyDefinition, value, path string) {
+	length := utf8.RuneCountInString(value)
+
+	if def.MinLength != nil && length < *def.MinLength {
+		v.addError(path, "length %d is less than minLength %d", length, *def.MinLength)
+	}
+	if def.MaxLength != nil && length > *def.MaxLength {
+		v.addError(path, "length %d is greater than maxLength %d", length, *def.MaxLength)
+	}
+	if def.Pattern != "" {
+		pattern, err := regexp.Compile(def.Pattern)
+		if err != nil {
+			v.addError(path, "invalid pattern %q: %s", def.Pattern, err)
+		} else if !pattern.MatchString(value) {
+			v.addError(path, "%q does not match pattern %q", value, def.Pattern)
+		}
+	}
+	if def.Format != "" && !matchesFormat(def.Format, value) {
+		v.addError(path, "%q is not a valid %s", value, def.Format)
+	}
+}
+
+func (v *validator) validateArray(def PropertyDefinition, value []interface{}, path string) {
+	if def.MinItems != nil && len(value) < *def.MinItems {
+		v.addError(path, "array has %d items, fewer than minItems %d", len(value), *def.MinItems)
+	}
+	if def.MaxItems != nil && len(value) > *def.MaxItems {
+		v.addError(path, "array has %d items, more than maxItems %d", len(value), *def.MaxItems)
+	}
+	if def.UniqueItems {
+		seen := map[string]int{}
+		for i, item := range value {
+			key := encodeValue(item)
+			if first, ok := seen[key]; ok {
+				v.addError(path, "items %d and %d are equal", first, i)
+				continue
+			}
+			seen[key] = i
+		}
+	}
+
+	for i, item := range value {
+		itemPath := path + "/" + strconv.Itoa(i)
+		// Tuples describe their leading items position by position, items describes the rest
+		if i < len(def.PrefixItems) {
+			v.validate(def.PrefixItems[i], item, itemPath)
+		} else if def.Items != nil {
+			v.validate(*def.Items, item, itemPath)
+		}
+	}
+}
+
+func (v *validator) validateObject(def PropertyDefinition, value map[string]interface{}, path string) {
+	for _, name := range def.Required {
+		if _, ok := value[name]; !ok {
+			v.addError(path, "missing required property %q", name)
+		}
+	}
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		itemPath := path + "/" + escapePointer(name)
+
+		if def.PropertyNames != nil {
+			v.validate(*def.PropertyNames, name, itemPath)
+		}
+
+		if property, ok := def.Properties[name]; ok {
+			v.validate(property, value[name], itemPath)
+		} else if def.AdditionalProperties != nil {
+			v.validate(*def.AdditionalProperties, value[name], itemPath)
+		}
+	}
+}
+
+// matchesAnyType reports whether a decoded JSON value is of one of the given types
+func matchesAnyType(types SchemaType, value interface{}) bool {
+	for _, t := range types {
+		if matchesType(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesType reports whether a decoded JSON value is of the given schema type
+func matchesType(schemaType string, value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return schemaType == typeNull
+	case bool:
+		return schemaType == "boolean"
+	case string:
+		return schemaType == "string"
+	case []interface{}:
+		return schemaType == typeArray
+	case map[string]interface{}:
+		return schemaType == "object"
+	case json.Number:
+		if schemaType == "number" {
+			return true
+		}
+		if schemaType != "integer" {
+			return false
+		}
+		n, ok := new(big.Float).SetString(value.String())
+		return ok && n.IsInt()
+	}
+	return false
+}
+
+// jsonTypeOf returns the JSON type name of a decoded value for error messages
+func jsonTypeOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return typeNull
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return typeArray
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if matchesType("integer", value) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// matchesFormat checks the formats emitted by the generator plus a few common
+// ones. Unknown formats are treated as annotations and always match.
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	case "byte":
+		_, err := base64.StdEncoding.DecodeString(value)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	case "ipv4":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	case "ipv6":
+		ip := net.ParseIP(value)
+		return ip != nil && strings.Contains(value, ":")
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.IsAbs()
+	}
+	return true
+}
+
+// containsValue reports whether value equals one of the enum entries. Values
+// are compared by their canonical JSON encoding so numbers match regardless
+// of their Go representation.
+func containsValue(enum []interface{}, value interface{}) bool {
+	encoded := encodeValue(value)
+	for _, candidate := range enum {
+		if encodeValue(candidate) == encoded {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeValue returns a canonical JSON encoding of a value used for equality checks
+func encodeValue(value interface{}) string {
+	if n, ok := value.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			value = f
+		}
+	}
+	if i, ok := value.(int64); ok {
+		value = float64(i)
+	}
+
+	switch value := value.(type) {
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = encodeValue(item)
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, key := range keys {
+			entries[i] = strconv.Quote(key) + ":" + encodeValue(value[key])
+		}
+		return "{" + strings.Join(entries, ",") + "}"
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// escapePointer escapes a property name for use as a JSON pointer token
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// unescapePointer reverses escapePointer
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
@@ -0,0 +1,155 @@
+package schematic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type ValidationItem struct {
+	SKU      string `json:"sku" jsonschema:"pattern=^[A-Z]{3}-[0-9]+$"`
+	Quantity int    `json:"quantity" jsonschema:"minimum=1"`
+	Price    float64
+}
+
+type ValidationOrder struct {
+	ID        string           `json:"id" jsonschema:"format=uuid"`
+	Status    string           `json:"status" jsonschema:"enum=open|closed"`
+	CreatedAt string           `json:"created_at" jsonschema:"format=date-time"`
+	Item      ValidationItem   `json:"item"`
+	Lines     []ValidationItem `json:"lines" jsonschema:"maxItems=2"`
+	Tags      []string         `json:"tags,omitempty" jsonschema:"uniqueItems"`
+	Note      string           `json:"note,omitempty" jsonschema:"maxLength=5"`
+}
+
+func TestValidateValidPayload(t *testing.T) {
+	schema := GenerateSchema(ValidationOrder{}, "Order", "http://json-schema.org/draft-07/schema#")
+
+	// The nested struct has three properties so it is placed in $defs
+	require.NotEmpty(t, schema.Properties["item"].Ref)
+
+	payload := `{
+		"id": "0b6d1c9e-4c2b-4d7e-9b1a-2f5f3c1e8a10",
+		"status": "open",
+		"created_at": "2023-05-01T10:00:00Z",
+		"item": {"sku": "ABC-1", "quantity": 2, "Price": 9.5},
+		"lines": [{"sku": "XYZ-22", "quantity": 1, "Price": 1}],
+		"tags": ["a", "b"]
+	}`
+
+	require.Empty(t, Validate(schema, []byte(payload)))
+}
+
+func TestValidateInvalidPayload(t *testing.T) {
+	schema := GenerateSchema(ValidationOrder{}, "Order", "http://json-schema.org/draft-07/schema#")
+
+	payload := `{
+		"id": "not-a-uuid",
+		"status": "pending",
+		"created_at": "yesterday",
+		"item": {"sku": "abc", "quantity": 0},
+		"lines": [{"sku": "XYZ-22", "quantity": 1.5, "Price": 1}, "x", {}],
+		"tags": ["a", "a"],
+		"note": "too long"
+	}`
+
+	errs := Validate(schema, []byte(payload))
+
+	paths := map[string][]string{}
+	for _, err := range errs {
+		paths[err.Path] = append(paths[err.Path], err.Message)
+	}
+
+	require.Contains(t, paths, "/id")
+	require.Contains(t, paths, "/status")
+	require.Contains(t, paths, "/created_at")
+	require.Contains(t, paths, "/item/sku")
+	require.Contains(t, paths, "/item/quantity")
+	require.Contains(t, paths, "/item")
+	require.Contains(t, paths["/item"], `missing required property "Price"`)
+	require.Contains(t, paths, "/lines")
+	require.Contains(t, paths["/lines/0/quantity"], "expected integer, got number")
+	require.Contains(t, paths, "/lines/1")
+	require.Contains(t, paths, "/tags")
+	require.Contains(t, paths, "/note")
+}
+
+func TestValidateRootErrors(t *testing.T) {
+	schema := GenerateSchema(EventTags{}, "Tags", "http://json-schema.org/draft-07/schema#")
+
+	errs := Validate(schema, []byte(`[]`))
+	require.Len(t, errs, 1)
+	require.Equal(t, "", errs[0].Path)
+	require.Equal(t, "(root): expected object, got array", errs[0].Error())
+
+	errs = Validate(schema, []byte(`{"event_name": "x"`))
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Message, "invalid JSON")
+
+	errs = Validate(schema, []byte(`{"event_name": "x"}`))
+	require.Len(t, errs, 2)
+}
+
+func TestValidateUnresolvedReference(t *testing.T) {
+	schema := Schema{
+		Type: "object",
+		Properties: map[string]PropertyDefinition{
+			"a/b": {Ref: "#/$defs/Missing"},
+		},
+	}
+
+	errs := Validate(schema, []byte(`{"a/b": {}}`))
+	require.Len(t, errs, 1)
+	require.Equal(t, "/a~1b", errs[0].Path)
+	require.Contains(t, errs[0].Message, "unresolved reference")
+}
+
+func TestValidateAnyOf(t *testing.T) {
+	schema := Schema{
+		Type: "object",
+		Properties: map[string]PropertyDefinition{
+			"item": {AnyOf: []PropertyDefinition{{Ref: "#/$defs/Item"}, {Type: SchemaType{"null"}}}},
+		},
+		Definitions: map[string]PropertyDefinition{
+			"Item": {Type: SchemaType{"object"}, Required: []string{"id"}},
+		},
+	}
+
+	require.Empty(t, Validate(schema, []byte(`{"item": null}`)))
+	require.Empty(t, Validate(schema, []byte(`{"item": {"id": 1}}`)))
+	require.Len(t, Validate(schema, []byte(`{"item": {}}`)), 1)
+}
+
+type ValidationPrice struct {
+	Amount float64 `json:"amount" jsonschema:"multipleOf=0.01"`
+	Rate   float64 `json:"rate" jsonschema:"multipleOf=0.1"`
+	Step   int     `json:"step" jsonschema:"multipleOf=5"`
+}
+
+func TestValidateMultipleOf(t *testing.T) {
+	schema := GenerateSchema(ValidationPrice{}, "Price", "")
+
+	// Decimal divisors are exact although 0.01 and 0.1 aren't in binary
+	for _, payload := range []string{
+		`{"amount": 0.07, "rate": 0.3, "step": 10}`,
+		`{"amount": 19.99, "rate": 1.1, "step": 0}`,
+		`{"amount": 1e2, "rate": 7, "step": -15}`,
+	} {
+		require.Empty(t, Validate(schema, []byte(payload)), payload)
+	}
+
+	errs := Validate(schema, []byte(`{"amount": 0.075, "rate": 0.35, "step": 12}`))
+	require.Equal(t, []string{
+		"/amount: 0.075 is not a multiple of 0.01",
+		"/rate: 0.35 is not a multiple of 0.1",
+		"/step: 12 is not a multiple of 5",
+	}, validationMessages(errs))
+}
+
+func validationMessages(errs []ValidationError) []string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return messages
+}